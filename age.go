@@ -0,0 +1,203 @@
+package switchfs
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Clock reports the current time. Conditions that depend on the time read it
+// from a Clock at evaluation time, so that tests and simulations can control
+// it.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface
+type ClockFunc func() time.Time
+
+// Now returns f()
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the wall clock
+var SystemClock Clock = ClockFunc(time.Now)
+
+// TimeField selects which timestamp of a file a condition inspects
+type TimeField int
+
+const (
+	// TimeModified is the modification time
+	TimeModified TimeField = iota
+	// TimeAccessed is the access time
+	TimeAccessed
+	// TimeChanged is the status change time
+	TimeChanged
+)
+
+// String returns the string representation of TimeField
+func (f TimeField) String() string {
+	switch f {
+	case TimeModified:
+		return "modified"
+	case TimeAccessed:
+		return "accessed"
+	case TimeChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// AgeCondition matches files by the age of one of their timestamps, measured
+// against a clock when the condition is evaluated. Like other conditions, it
+// matches when the timestamp cannot be determined.
+type AgeCondition struct {
+	// Field is the timestamp to inspect
+	Field TimeField
+
+	// MinAge and MaxAge bound the age. Zero leaves a bound open.
+	MinAge time.Duration
+	MaxAge time.Duration
+
+	// Clock provides the current time. Nil means SystemClock.
+	Clock Clock
+}
+
+// Evaluate reports whether the file's age is within the condition's bounds
+func (c *AgeCondition) Evaluate(path string, info os.FileInfo) bool {
+	if info == nil {
+		return true // Can't evaluate, assume match
+	}
+
+	stamp, ok := fileTime(info, c.Field)
+	if !ok {
+		return true // Timestamp not exposed, assume match
+	}
+
+	clock := c.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	age := clock.Now().Sub(stamp)
+
+	if c.MinAge > 0 && age < c.MinAge {
+		return false
+	}
+
+	if c.MaxAge > 0 && age > c.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+// String returns the constructor call creating the condition
+func (c *AgeCondition) String() string {
+	field := strings.ToUpper(c.Field.String()[:1]) + c.Field.String()[1:]
+	switch {
+	case c.MaxAge == 0:
+		return fmt.Sprintf("%sOlderThan(%v)", field, c.MinAge)
+	case c.MinAge == 0:
+		return fmt.Sprintf("%sNewerThan(%v)", field, c.MaxAge)
+	case c.Field == TimeModified:
+		return fmt.Sprintf("ModifiedWithin(%v, %v)", c.MinAge, c.MaxAge)
+	default:
+		return fmt.Sprintf("AgeCondition(%v, %v, %v)", c.Field, c.MinAge, c.MaxAge)
+	}
+}
+
+// WithClock sets the clock the condition measures ages against
+func (c *AgeCondition) WithClock(clock Clock) *AgeCondition {
+	c.Clock = clock
+	return c
+}
+
+// ModifiedOlderThan creates a condition that matches files last modified at
+// least d ago
+func ModifiedOlderThan(d time.Duration) *AgeCondition {
+	return &AgeCondition{Field: TimeModified, MinAge: d}
+}
+
+// ModifiedNewerThan creates a condition that matches files modified within
+// the last d
+func ModifiedNewerThan(d time.Duration) *AgeCondition {
+	return &AgeCondition{Field: TimeModified, MaxAge: d}
+}
+
+// ModifiedWithin creates a condition that matches files whose modification
+// age is between minAge and maxAge
+func ModifiedWithin(minAge, maxAge time.Duration) *AgeCondition {
+	return &AgeCondition{Field: TimeModified, MinAge: minAge, MaxAge: maxAge}
+}
+
+// AccessedOlderThan creates a condition that matches files last accessed at
+// least d ago
+func AccessedOlderThan(d time.Duration) *AgeCondition {
+	return &AgeCondition{Field: TimeAccessed, MinAge: d}
+}
+
+// AccessedNewerThan creates a condition that matches files accessed within
+// the last d
+func AccessedNewerThan(d time.Duration) *AgeCondition {
+	return &AgeCondition{Field: TimeAccessed, MaxAge: d}
+}
+
+// ChangedOlderThan creates a condition that matches files whose status last
+// changed at least d ago
+func ChangedOlderThan(d time.Duration) *AgeCondition {
+	return &AgeCondition{Field: TimeChanged, MinAge: d}
+}
+
+// ChangedNewerThan creates a condition that matches files whose status
+// changed within the last d
+func ChangedNewerThan(d time.Duration) *AgeCondition {
+	return &AgeCondition{Field: TimeChanged, MaxAge: d}
+}
+
+// fileTime returns a timestamp of a file. Access and change times come from
+// FileInfo.Sys(), either through Atime and Ctime methods or through the
+// timespec fields of *syscall.Stat_t.
+func fileTime(info os.FileInfo, field TimeField) (time.Time, bool) {
+	switch field {
+	case TimeModified:
+		return info.ModTime(), true
+	case TimeAccessed:
+		if t, ok := info.Sys().(interface{ Atime() time.Time }); ok {
+			return t.Atime(), true
+		}
+		return timespecField(info.Sys(), "Atim", "Atimespec")
+	case TimeChanged:
+		if t, ok := info.Sys().(interface{ Ctime() time.Time }); ok {
+			return t.Ctime(), true
+		}
+		return timespecField(info.Sys(), "Ctim", "Ctimespec")
+	}
+	return time.Time{}, false
+}
+
+// timespecField reads the first of the named timespec fields of a Sys value
+// that is a pointer to a struct. A timespec is a struct with integer Sec and
+// Nsec fields.
+func timespecField(sys any, names ...string) (time.Time, bool) {
+	v := reflect.ValueOf(sys)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return time.Time{}, false
+	}
+
+	for _, name := range names {
+		ts := v.Elem().FieldByName(name)
+		if !ts.IsValid() || ts.Kind() != reflect.Struct {
+			continue
+		}
+		sec, nsec := ts.FieldByName("Sec"), ts.FieldByName("Nsec")
+		if !isIntField(sec) || !isIntField(nsec) {
+			continue
+		}
+		return time.Unix(int64(intOf(sec)), int64(intOf(nsec))), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,95 @@
+package switchfs
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// timespec mirrors the layout of syscall.Timespec
+type timespec struct {
+	Sec  int64
+	Nsec int64
+}
+
+// statSys mirrors the timestamp fields of syscall.Stat_t on Linux
+type statSys struct {
+	Atim timespec
+	Ctim timespec
+}
+
+// inodeSys exposes timestamps through methods, like memfs inodes
+type inodeSys struct {
+	atime, ctime time.Time
+}
+
+func (s *inodeSys) Atime() time.Time { return s.atime }
+func (s *inodeSys) Ctime() time.Time { return s.ctime }
+
+// sysFileInfo is a mockFileInfo with a Sys value
+type sysFileInfo struct {
+	mockFileInfo
+	sys any
+}
+
+func (s *sysFileInfo) Sys() interface{} { return s.sys }
+
+func TestAgeCondition(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	clock := ClockFunc(func() time.Time { return now })
+	day := 24 * time.Hour
+
+	modified := &mockFileInfo{modTime: now.Add(-10 * day)}
+	stat := &sysFileInfo{sys: &statSys{
+		Atim: timespec{Sec: now.Add(-40 * day).Unix()},
+		Ctim: timespec{Sec: now.Add(-2 * day).Unix()},
+	}}
+	inode := &sysFileInfo{sys: &inodeSys{atime: now.Add(-2 * day), ctime: now.Add(-40 * day)}}
+
+	tests := []struct {
+		name string
+		cond *AgeCondition
+		info os.FileInfo
+		want bool
+	}{
+		{"modified older than, old file", ModifiedOlderThan(7 * day), modified, true},
+		{"modified older than, recent file", ModifiedOlderThan(30 * day), modified, false},
+		{"modified newer than, recent file", ModifiedNewerThan(30 * day), modified, true},
+		{"modified newer than, old file", ModifiedNewerThan(7 * day), modified, false},
+		{"modified within", ModifiedWithin(7*day, 30*day), modified, true},
+		{"modified outside", ModifiedWithin(20*day, 30*day), modified, false},
+		{"accessed from stat", AccessedOlderThan(30 * day), stat, true},
+		{"changed from stat", ChangedNewerThan(7 * day), stat, true},
+		{"accessed from methods", AccessedNewerThan(7 * day), inode, true},
+		{"changed from methods", ChangedOlderThan(30 * day), inode, true},
+		{"changed from methods, too recent", ChangedOlderThan(60 * day), inode, false},
+		{"access time not exposed assumes match", AccessedOlderThan(30 * day), modified, true},
+		{"nil FileInfo assumes match", ModifiedOlderThan(day), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.WithClock(clock).Evaluate("/test/path", tt.info); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("evaluated against the clock, not construction time", func(t *testing.T) {
+		cond := ModifiedOlderThan(30 * day).WithClock(clock)
+		if cond.Evaluate("/f", modified) {
+			t.Fatal("10-day-old file matched older than 30 days")
+		}
+		now = now.Add(25 * day)
+		if !cond.Evaluate("/f", modified) {
+			t.Error("35-day-old file did not match older than 30 days")
+		}
+	})
+
+	t.Run("system clock by default", func(t *testing.T) {
+		recent := &mockFileInfo{modTime: time.Now().Add(-time.Minute)}
+		if !ModifiedNewerThan(time.Hour).Evaluate("/f", recent) {
+			t.Error("file modified a minute ago did not match newer than an hour")
+		}
+	})
+}
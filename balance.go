@@ -0,0 +1,384 @@
+package switchfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/absfs/absfs"
+)
+
+// defaultHealthCooldown is how long a failed target stays out of rotation
+const defaultHealthCooldown = 30 * time.Second
+
+// BalancePolicy defines how a balanced route picks a target
+type BalancePolicy int
+
+const (
+	// BalanceRoundRobin cycles through targets in order
+	BalanceRoundRobin BalancePolicy = iota
+	// BalanceWeighted cycles through targets in proportion to their weights
+	BalanceWeighted
+	// BalanceLeastOutstanding picks the target with the fewest in-flight
+	// calls and open files
+	BalanceLeastOutstanding
+)
+
+// String returns the string representation of BalancePolicy
+func (p BalancePolicy) String() string {
+	switch p {
+	case BalanceRoundRobin:
+		return "round-robin"
+	case BalanceWeighted:
+		return "weighted"
+	case BalanceLeastOutstanding:
+		return "least-outstanding"
+	default:
+		return "unknown"
+	}
+}
+
+// Target is one of several equivalent backends of a balanced route
+type Target struct {
+	// Backend is the target's filesystem
+	Backend absfs.FileSystem
+
+	// Weight is the target's share of calls under BalanceWeighted. Zero
+	// counts as one.
+	Weight int
+}
+
+// balanceTarget tracks the state of one target
+type balanceTarget struct {
+	backend        absfs.FileSystem
+	weight         int
+	current        int
+	outstanding    int
+	unhealthyUntil time.Time
+}
+
+// balancedFS spreads reads across equivalent targets. A target that fails
+// with a backend error is taken out of rotation for a cooldown period and the
+// call is retried on another target, falling back to the failover backend
+// when no target is healthy. Mutations, including opens for writing, are
+// applied to every target so that they stay equivalent.
+type balancedFS struct {
+	mu       sync.Mutex
+	targets  []*balanceTarget
+	policy   BalancePolicy
+	failover absfs.FileSystem
+	cooldown time.Duration
+	next     int
+	now      func() time.Time
+
+	// writes fans mutations out to every target
+	writes *mirrorFS
+}
+
+// Ensure balancedFS implements absfs.SymlinkFileSystem
+var _ absfs.SymlinkFileSystem = (*balancedFS)(nil)
+
+// newBalancedFS builds the balancer for a route with targets
+func newBalancedFS(route *Route) (*balancedFS, error) {
+	b := &balancedFS{
+		policy:   route.Balance,
+		failover: route.Failover,
+		cooldown: route.HealthCooldown,
+		now:      time.Now,
+	}
+	if b.cooldown == 0 {
+		b.cooldown = defaultHealthCooldown
+	}
+
+	var replicas []absfs.FileSystem
+	for _, target := range route.Targets {
+		if target.Backend == nil {
+			return nil, ErrNilBackend
+		}
+		weight := target.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		b.targets = append(b.targets, &balanceTarget{backend: target.Backend, weight: weight})
+		replicas = append(replicas, target.Backend)
+	}
+
+	quorum := route.WriteQuorum
+	if quorum == 0 {
+		quorum = len(replicas)
+	}
+	if quorum < 1 || quorum > len(replicas) {
+		return nil, ErrInvalidQuorum
+	}
+	b.writes = &mirrorFS{replicas: replicas, quorum: quorum, onDivergence: route.OnDivergence}
+	return b, nil
+}
+
+// acquire picks a healthy target not yet tried and counts a call against it
+func (b *balancedFS) acquire(tried map[*balanceTarget]bool) *balanceTarget {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	var healthy []*balanceTarget
+	for _, t := range b.targets {
+		if !tried[t] && !now.Before(t.unhealthyUntil) {
+			healthy = append(healthy, t)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+
+	var picked *balanceTarget
+	switch b.policy {
+	case BalanceWeighted:
+		// Smooth weighted round-robin
+		total := 0
+		for _, t := range healthy {
+			t.current += t.weight
+			total += t.weight
+			if picked == nil || t.current > picked.current {
+				picked = t
+			}
+		}
+		picked.current -= total
+	case BalanceLeastOutstanding:
+		for _, t := range healthy {
+			if picked == nil || t.outstanding < picked.outstanding {
+				picked = t
+			}
+		}
+	default:
+		picked = healthy[b.next%len(healthy)]
+		b.next++
+	}
+
+	picked.outstanding++
+	return picked
+}
+
+// release ends a call against a target, taking it out of rotation if the
+// call failed with a backend error
+func (b *balancedFS) release(t *balanceTarget, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t.outstanding--
+	if failed {
+		t.unhealthyUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// failureErrnos are the system errors reporting an I/O or connection
+// problem with a backend rather than an answer about the filesystem
+var failureErrnos = []syscall.Errno{
+	syscall.EIO, syscall.ENXIO, syscall.ENODEV, syscall.ESTALE, syscall.ETIMEDOUT,
+	syscall.ENOTCONN, syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ECONNABORTED,
+	syscall.EHOSTDOWN, syscall.EHOSTUNREACH, syscall.ENETDOWN, syscall.ENETUNREACH,
+}
+
+// isBackendFailure reports whether an error indicates an unhealthy backend
+// rather than an answer about the filesystem, such as a missing file, or a
+// limit of the call, such as a canceled context or an exceeded quota
+func isBackendFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		for _, failure := range failureErrnos {
+			if errno == failure {
+				return true
+			}
+		}
+		return false
+	}
+	for _, answer := range []error{
+		fs.ErrNotExist, fs.ErrExist, fs.ErrPermission, fs.ErrInvalid, fs.ErrClosed, ErrSymlinksNotSupported,
+		context.Canceled, context.DeadlineExceeded, ErrQuotaExceeded, ErrRateLimited,
+	} {
+		if errors.Is(err, answer) {
+			return false
+		}
+	}
+	return true
+}
+
+// balanced runs fn on a picked target, retrying on other targets while it
+// fails with backend errors. A target stays acquired when keep reports true
+// for the result, so that open files count as outstanding.
+func balanced[T any](b *balancedFS, fn func(absfs.FileSystem) (T, error), keep func(T) bool) (T, *balanceTarget, error) {
+	var zero T
+	var lastErr error
+	tried := make(map[*balanceTarget]bool)
+
+	for {
+		t := b.acquire(tried)
+		if t == nil {
+			break
+		}
+		tried[t] = true
+
+		v, err := fn(t.backend)
+		failed := isBackendFailure(err)
+		if failed {
+			b.release(t, true)
+			lastErr = err
+			continue
+		}
+		if err != nil || keep == nil || !keep(v) {
+			b.release(t, false)
+			return v, nil, err
+		}
+		return v, t, nil
+	}
+
+	if b.failover != nil {
+		v, err := fn(b.failover)
+		return v, nil, err
+	}
+	if lastErr != nil {
+		return zero, nil, fmt.Errorf("%w: %v", ErrAllBackendsFailed, lastErr)
+	}
+	return zero, nil, ErrAllBackendsFailed
+}
+
+// OpenFile opens name on a balanced target for reading. The returned file
+// stays pinned to that target and counts as outstanding until it is closed.
+// Opens for writing open the file on every target.
+func (b *balancedFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	if openOperation(flag).IsWrite() {
+		return b.writes.OpenFile(name, flag, perm)
+	}
+
+	f, t, err := balanced(b, func(backend absfs.FileSystem) (absfs.File, error) {
+		return backend.OpenFile(name, flag, perm)
+	}, func(absfs.File) bool { return true })
+	if err != nil || t == nil {
+		return f, err
+	}
+	return &balancedFile{File: f, fs: b, target: t}, nil
+}
+
+func (b *balancedFS) Open(name string) (absfs.File, error) {
+	return b.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (b *balancedFS) Create(name string) (absfs.File, error) {
+	return b.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (b *balancedFS) Mkdir(name string, perm os.FileMode) error {
+	return b.writes.Mkdir(name, perm)
+}
+
+func (b *balancedFS) MkdirAll(name string, perm os.FileMode) error {
+	return b.writes.MkdirAll(name, perm)
+}
+
+func (b *balancedFS) Remove(name string) error {
+	return b.writes.Remove(name)
+}
+
+func (b *balancedFS) RemoveAll(name string) error {
+	return b.writes.RemoveAll(name)
+}
+
+func (b *balancedFS) Rename(oldpath, newpath string) error {
+	return b.writes.Rename(oldpath, newpath)
+}
+
+func (b *balancedFS) Chmod(name string, mode os.FileMode) error {
+	return b.writes.Chmod(name, mode)
+}
+
+func (b *balancedFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return b.writes.Chtimes(name, atime, mtime)
+}
+
+func (b *balancedFS) Chown(name string, uid, gid int) error {
+	return b.writes.Chown(name, uid, gid)
+}
+
+func (b *balancedFS) Truncate(name string, size int64) error {
+	return b.writes.Truncate(name, size)
+}
+
+func (b *balancedFS) Stat(name string) (os.FileInfo, error) {
+	info, _, err := balanced(b, func(backend absfs.FileSystem) (os.FileInfo, error) { return backend.Stat(name) }, nil)
+	return info, err
+}
+
+func (b *balancedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, _, err := balanced(b, func(backend absfs.FileSystem) ([]fs.DirEntry, error) { return backend.ReadDir(name) }, nil)
+	return entries, err
+}
+
+func (b *balancedFS) ReadFile(name string) ([]byte, error) {
+	data, _, err := balanced(b, func(backend absfs.FileSystem) ([]byte, error) { return backend.ReadFile(name) }, nil)
+	return data, err
+}
+
+func (b *balancedFS) Sub(dir string) (fs.FS, error) {
+	sub, _, err := balanced(b, func(backend absfs.FileSystem) (fs.FS, error) { return backend.Sub(dir) }, nil)
+	return sub, err
+}
+
+func (b *balancedFS) Chdir(dir string) error {
+	return b.targets[0].backend.Chdir(dir)
+}
+
+func (b *balancedFS) Getwd() (string, error) {
+	return b.targets[0].backend.Getwd()
+}
+
+func (b *balancedFS) TempDir() string {
+	return b.targets[0].backend.TempDir()
+}
+
+func (b *balancedFS) Lstat(name string) (os.FileInfo, error) {
+	info, _, err := balanced(b, func(backend absfs.FileSystem) (os.FileInfo, error) { return lstatBackend(backend, name) }, nil)
+	return info, err
+}
+
+func (b *balancedFS) Readlink(name string) (string, error) {
+	target, _, err := balanced(b, func(backend absfs.FileSystem) (string, error) {
+		linker, ok := backend.(absfs.SymLinker)
+		if !ok {
+			return "", &os.PathError{Op: "readlink", Path: name, Err: ErrSymlinksNotSupported}
+		}
+		return linker.Readlink(name)
+	}, nil)
+	return target, err
+}
+
+func (b *balancedFS) Symlink(oldname, newname string) error {
+	return b.writes.Symlink(oldname, newname)
+}
+
+func (b *balancedFS) Lchown(name string, uid, gid int) error {
+	return b.writes.Lchown(name, uid, gid)
+}
+
+// balancedFile is a file pinned to the target that opened it
+type balancedFile struct {
+	absfs.File
+	fs     *balancedFS
+	target *balanceTarget
+	once   sync.Once
+}
+
+// Close closes the file and releases its target
+func (f *balancedFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(func() {
+		f.fs.release(f.target, false)
+	})
+	return err
+}
@@ -0,0 +1,234 @@
+package switchfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/absfs/absfs"
+)
+
+// countingFS counts the opens served by a target
+type countingFS struct {
+	absfs.FileSystem
+	opens int
+}
+
+func (c *countingFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	c.opens++
+	return c.FileSystem.OpenFile(name, flag, perm)
+}
+
+// newTargets creates counting targets holding the same /data/file.txt
+func newTargets(t *testing.T, weights ...int) ([]Target, []*countingFS) {
+	t.Helper()
+
+	targets := make([]Target, len(weights))
+	counters := make([]*countingFS, len(weights))
+	for i, replica := range newReplicas(t, len(weights)) {
+		writeTestFile(t, replica, "/data/file.txt", "replicated")
+		counters[i] = &countingFS{FileSystem: replica}
+		targets[i] = Target{Backend: counters[i], Weight: weights[i]}
+	}
+	return targets, counters
+}
+
+// newBalancer builds the balancer of a route with targets
+func newBalancer(t *testing.T, policy BalancePolicy, targets []Target) *balancedFS {
+	t.Helper()
+
+	b, err := newBalancedFS(&Route{Targets: targets, Balance: policy})
+	if err != nil {
+		t.Fatalf("newBalancedFS() error = %v", err)
+	}
+	return b
+}
+
+// openAndClose opens and closes a file n times
+func openAndClose(t *testing.T, fs absfs.FileSystem, name string, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		f, err := fs.Open(name)
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		f.Close()
+	}
+}
+
+func TestBalancedRoute(t *testing.T) {
+	t.Run("round-robin", func(t *testing.T) {
+		targets, counters := newTargets(t, 0, 0, 0)
+		fs := newBalancer(t, BalanceRoundRobin, targets)
+
+		openAndClose(t, fs, "/data/file.txt", 9)
+		for i, c := range counters {
+			if c.opens != 3 {
+				t.Errorf("target %d served %d of 9 opens, want 3", i, c.opens)
+			}
+		}
+	})
+
+	t.Run("weighted", func(t *testing.T) {
+		targets, counters := newTargets(t, 3, 1)
+		fs := newBalancer(t, BalanceWeighted, targets)
+
+		openAndClose(t, fs, "/data/file.txt", 8)
+		if counters[0].opens != 6 || counters[1].opens != 2 {
+			t.Errorf("opens = %d, %d, want 6, 2", counters[0].opens, counters[1].opens)
+		}
+	})
+
+	t.Run("least outstanding counts open files", func(t *testing.T) {
+		targets, counters := newTargets(t, 0, 0)
+		fs := newBalancer(t, BalanceLeastOutstanding, targets)
+
+		held, err := fs.Open("/data/file.txt")
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		openAndClose(t, fs, "/data/file.txt", 3)
+		if counters[0].opens != 1 || counters[1].opens != 3 {
+			t.Errorf("opens = %d, %d, want 1, 3", counters[0].opens, counters[1].opens)
+		}
+
+		held.Close()
+		openAndClose(t, fs, "/data/file.txt", 1)
+		if counters[0].opens != 2 {
+			t.Errorf("target 0 served %d opens after close, want 2", counters[0].opens)
+		}
+	})
+
+	t.Run("mutations reach every target", func(t *testing.T) {
+		targets, counters := newTargets(t, 0, 0)
+		fs, err := New(WithBalancedRoute("/data", BalanceRoundRobin, targets))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		f, err := fs.Create("/data/new.txt")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		openAndClose(t, fs, "/data/file.txt", 3)
+		if _, err := f.Write([]byte("replicated")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		f.Close()
+		if err := fs.Mkdir("/data/dir", 0755); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		if err := fs.Remove("/data/file.txt"); err != nil {
+			t.Fatalf("Remove() error = %v", err)
+		}
+
+		for i, c := range counters {
+			if data, err := c.ReadFile("/data/new.txt"); err != nil || string(data) != "replicated" {
+				t.Errorf("target %d ReadFile() = %q, %v", i, data, err)
+			}
+			if _, err := c.Stat("/data/dir"); err != nil {
+				t.Errorf("target %d directory missing: %v", i, err)
+			}
+			if _, err := c.Stat("/data/file.txt"); !os.IsNotExist(err) {
+				t.Errorf("target %d kept the removed file: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("unhealthy target leaves rotation", func(t *testing.T) {
+		targets, counters := newTargets(t, 0, 0)
+		broken := &brokenFS{FileSystem: counters[0]}
+		targets[0].Backend = broken
+
+		fs, err := New(WithBalancedRoute("/data", BalanceRoundRobin, targets, WithHealthCooldown(time.Minute)))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		route, err := fs.routeOperation(OpOpen, "/data/file.txt")
+		if err != nil {
+			t.Fatalf("RouteOperation() error = %v", err)
+		}
+		now := time.Now()
+		route.balanced.now = func() time.Time { return now }
+
+		openAndClose(t, fs, "/data/file.txt", 4)
+		if counters[1].opens != 4 {
+			t.Errorf("healthy target served %d of 4 opens, want 4", counters[1].opens)
+		}
+
+		// A missing file is an answer, not a failure
+		if _, err := fs.Open("/data/missing.txt"); !os.IsNotExist(err) {
+			t.Errorf("Open() error = %v, want not exist", err)
+		}
+
+		// The broken target rejoins after the cooldown and fails again
+		now = now.Add(2 * time.Minute)
+		openAndClose(t, fs, "/data/file.txt", 2)
+		if counters[1].opens != 7 {
+			t.Errorf("healthy target served %d opens, want 7", counters[1].opens)
+		}
+	})
+
+	t.Run("failover when no target is healthy", func(t *testing.T) {
+		targets, _ := newTargets(t, 0)
+		targets[0].Backend = &brokenFS{FileSystem: targets[0].Backend}
+		backup := newReplicas(t, 1)[0]
+		writeTestFile(t, backup, "/data/file.txt", "backup")
+
+		fs, err := New(WithBalancedRoute("/data", BalanceRoundRobin, targets, WithFailover(backup)))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		data, err := fs.ReadFile("/data/file.txt")
+		if err != nil || string(data) != "backup" {
+			t.Errorf("ReadFile() = %q, %v", data, err)
+		}
+
+		fs, err = New(WithBalancedRoute("/data", BalanceRoundRobin, targets))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if _, err := fs.ReadFile("/data/file.txt"); !errors.Is(err, ErrAllBackendsFailed) {
+			t.Errorf("ReadFile() error = %v, want ErrAllBackendsFailed", err)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		if _, err := New(WithBalancedRoute("/data", BalanceRoundRobin, nil)); err != ErrNilBackend {
+			t.Errorf("New() error = %v, want ErrNilBackend", err)
+		}
+		if _, err := New(WithBalancedRoute("/data", BalanceRoundRobin, []Target{{}})); err != ErrNilBackend {
+			t.Errorf("New() error = %v, want ErrNilBackend", err)
+		}
+	})
+}
+
+func TestIsBackendFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"not exist", &os.PathError{Op: "open", Path: "/f", Err: os.ErrNotExist}, false},
+		{"errno answer", &os.PathError{Op: "open", Path: "/f", Err: syscall.ENOTDIR}, false},
+		{"canceled", context.Canceled, false},
+		{"quota", quotaError("write", "/f", ErrQuotaExceeded), false},
+		{"rate limit", rateError("open", "/f", ErrRateLimited), false},
+		{"io error", &os.PathError{Op: "read", Path: "/f", Err: syscall.EIO}, true},
+		{"connection refused", syscall.ECONNREFUSED, true},
+		{"opaque backend error", errBroken, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBackendFailure(tt.err); got != tt.want {
+				t.Errorf("isBackendFailure(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package switchfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/absfs/absfs"
+)
+
+// defaultStatusInterval is how long a backend's reported status is reused
+// before the backend is asked again
+const defaultStatusInterval = 10 * time.Second
+
+// BackendStatus is the health and capacity a backend reports
+type BackendStatus struct {
+	// Healthy reports whether the backend is serving requests
+	Healthy bool
+
+	// TotalBytes and FreeBytes describe the backend's storage. Zero
+	// TotalBytes means the capacity is unknown.
+	TotalBytes int64
+	FreeBytes  int64
+}
+
+// StatusReporter is implemented by backends that can report their health
+// and capacity. Backends without it are assumed healthy with unknown
+// capacity. An error reports the backend as unhealthy.
+type StatusReporter interface {
+	Status() (BackendStatus, error)
+}
+
+// statusCache polls backends for their status, reusing each answer for an
+// interval
+type statusCache struct {
+	mu       sync.Mutex
+	interval time.Duration
+	clock    Clock
+	entries  map[absfs.FileSystem]statusEntry
+}
+
+// statusEntry is a cached backend status
+type statusEntry struct {
+	status  BackendStatus
+	checked time.Time
+}
+
+// newStatusCache creates a status cache polling at most once per interval
+func newStatusCache(interval time.Duration) *statusCache {
+	return &statusCache{
+		interval: interval,
+		clock:    SystemClock,
+		entries:  make(map[absfs.FileSystem]statusEntry),
+	}
+}
+
+// defaultStatus caches the status of backends for conditions evaluated
+// outside a SwitchFS
+var defaultStatus = newStatusCache(defaultStatusInterval)
+
+// status returns a backend's status, polling it when the cached status is
+// older than the interval. It reports false for backends that do not report
+// their status.
+func (c *statusCache) status(backend absfs.FileSystem) (BackendStatus, bool) {
+	reporter, ok := backend.(StatusReporter)
+	if !ok {
+		return BackendStatus{}, false
+	}
+	cacheable := reflect.TypeOf(backend).Comparable()
+
+	now := c.clock.Now()
+	if cacheable {
+		c.mu.Lock()
+		entry, ok := c.entries[backend]
+		c.mu.Unlock()
+		if ok && now.Sub(entry.checked) < c.interval {
+			return entry.status, true
+		}
+	}
+
+	status, err := reporter.Status()
+	if err != nil {
+		status = BackendStatus{}
+	}
+
+	if cacheable {
+		c.mu.Lock()
+		c.entries[backend] = statusEntry{status: status, checked: now}
+		c.mu.Unlock()
+	}
+	return status, true
+}
+
+// statusKey is the context key under which SwitchFS passes its status cache
+// to conditions
+type statusKey struct{}
+
+// statusFrom returns the status cache carried by ctx, or the default one
+func statusFrom(ctx context.Context) *statusCache {
+	if cache, ok := ctx.Value(statusKey{}).(*statusCache); ok {
+		return cache
+	}
+	return defaultStatus
+}
+
+// backendCondition matches while a backend is healthy and has enough free
+// space
+type backendCondition struct {
+	backend  absfs.FileSystem
+	minFree  int64
+	minRatio float64
+}
+
+func (c *backendCondition) Evaluate(path string, info os.FileInfo) bool {
+	return c.match(defaultStatus)
+}
+
+func (c *backendCondition) EvaluateContext(ctx context.Context, path string, info os.FileInfo) bool {
+	return c.match(statusFrom(ctx))
+}
+
+// match checks the backend's status from a cache
+func (c *backendCondition) match(cache *statusCache) bool {
+	status, ok := cache.status(c.backend)
+	if !ok {
+		return true // Status not reported, assume match
+	}
+	if !status.Healthy {
+		return false
+	}
+	if status.TotalBytes == 0 {
+		return true // Capacity unknown, assume match
+	}
+	if status.FreeBytes < c.minFree {
+		return false
+	}
+	return float64(status.FreeBytes) >= c.minRatio*float64(status.TotalBytes)
+}
+
+func (c *backendCondition) String() string {
+	switch {
+	case c.minFree > 0:
+		return fmt.Sprintf("BackendFreeAbove(%T, %s)", c.backend, formatBytes(c.minFree))
+	case c.minRatio > 0:
+		return fmt.Sprintf("BackendFreeRatioAbove(%T, %g)", c.backend, c.minRatio)
+	default:
+		return fmt.Sprintf("BackendHealthy(%T)", c.backend)
+	}
+}
+
+// BackendHealthy creates a condition that matches while a backend reports
+// itself healthy. SwitchFS polls the backend at most once per status
+// interval; backends that do not implement StatusReporter always match.
+func BackendHealthy(backend absfs.FileSystem) RouteCondition {
+	return &backendCondition{backend: backend}
+}
+
+// BackendFreeAbove creates a condition that matches while a backend is
+// healthy and reports at least bytes of free space, so that a lower-priority
+// overflow route takes over as it fills up
+func BackendFreeAbove(backend absfs.FileSystem, bytes int64) RouteCondition {
+	return &backendCondition{backend: backend, minFree: bytes}
+}
+
+// BackendFreeRatioAbove creates a condition that matches while a backend is
+// healthy and reports at least the given fraction of its storage free
+func BackendFreeRatioAbove(backend absfs.FileSystem, fraction float64) RouteCondition {
+	return &backendCondition{backend: backend, minRatio: fraction}
+}
@@ -0,0 +1,166 @@
+package switchfs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/memfs"
+)
+
+// reportingFS is a backend reporting a settable status
+type reportingFS struct {
+	absfs.FileSystem
+	status BackendStatus
+	err    error
+	polls  int
+}
+
+func (r *reportingFS) Status() (BackendStatus, error) {
+	r.polls++
+	return r.status, r.err
+}
+
+func TestBackendConditions(t *testing.T) {
+	mem, _ := memfs.NewFS()
+	backend := &reportingFS{FileSystem: mem}
+
+	tests := []struct {
+		name   string
+		status BackendStatus
+		err    error
+		cond   RouteCondition
+		want   bool
+	}{
+		{"healthy", BackendStatus{Healthy: true}, nil, BackendHealthy(backend), true},
+		{"unhealthy", BackendStatus{}, nil, BackendHealthy(backend), false},
+		{"error", BackendStatus{Healthy: true}, errors.New("down"), BackendHealthy(backend), false},
+		{"free above", BackendStatus{Healthy: true, TotalBytes: 100, FreeBytes: 60}, nil, BackendFreeAbove(backend, 50), true},
+		{"free below", BackendStatus{Healthy: true, TotalBytes: 100, FreeBytes: 40}, nil, BackendFreeAbove(backend, 50), false},
+		{"capacity unknown", BackendStatus{Healthy: true}, nil, BackendFreeAbove(backend, 50), true},
+		{"ratio above", BackendStatus{Healthy: true, TotalBytes: 100, FreeBytes: 25}, nil, BackendFreeRatioAbove(backend, 0.2), true},
+		{"ratio below", BackendStatus{Healthy: true, TotalBytes: 100, FreeBytes: 15}, nil, BackendFreeRatioAbove(backend, 0.2), false},
+		{"not reported", BackendStatus{}, nil, BackendHealthy(mem), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend.status, backend.err = tt.status, tt.err
+			cache := newStatusCache(0)
+			if got := tt.cond.(*backendCondition).match(cache); got != tt.want {
+				t.Errorf("%v = %v, want %v", tt.cond, got, tt.want)
+			}
+		})
+	}
+
+	if got := describe(BackendFreeAbove(backend, 10<<30)); got != "BackendFreeAbove(*switchfs.reportingFS, 10GiB)" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestOverflowRouting(t *testing.T) {
+	mem, _ := memfs.NewFS()
+	overflow, _ := memfs.NewFS()
+	primary := &reportingFS{FileSystem: mem, status: BackendStatus{Healthy: true, TotalBytes: 1000, FreeBytes: 900}}
+	mem.MkdirAll("/data", 0755)
+	overflow.MkdirAll("/data", 0755)
+
+	fs, err := New(
+		WithRoute("/data", primary,
+			WithCondition(BackendFreeAbove(primary, 100)),
+			WithOperations(OpCreate),
+			WithPriority(10)),
+		WithRoute("/data", overflow),
+		WithStatusInterval(time.Minute),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	fs.status.clock = ClockFunc(func() time.Time { return now })
+
+	writeTestFile(t, fs, "/data/a.txt", "a")
+	writeTestFile(t, fs, "/data/b.txt", "b")
+	if primary.polls != 1 {
+		t.Errorf("polls = %d, want 1 within the interval", primary.polls)
+	}
+
+	// The primary fills up; the cached status is used until it expires
+	primary.status.FreeBytes = 50
+	writeTestFile(t, fs, "/data/c.txt", "c")
+	now = now.Add(2 * time.Minute)
+	writeTestFile(t, fs, "/data/d.txt", "d")
+
+	for name, want := range map[string]absfs.FileSystem{
+		"/data/a.txt": mem,
+		"/data/b.txt": mem,
+		"/data/c.txt": mem,
+		"/data/d.txt": overflow,
+	} {
+		if _, err := want.Stat(name); err != nil {
+			t.Errorf("%s not on expected backend: %v", name, err)
+		}
+	}
+	if primary.polls != 2 {
+		t.Errorf("polls = %d, want 2", primary.polls)
+	}
+}
+
+// TestOverflowRoutingWithProbing runs the capacity routing example of the
+// README end to end
+func TestOverflowRoutingWithProbing(t *testing.T) {
+	mem, _ := memfs.NewFS()
+	overflow, _ := memfs.NewFS()
+	primary := &reportingFS{FileSystem: mem, status: BackendStatus{Healthy: true, TotalBytes: 1000, FreeBytes: 900}}
+
+	fs, err := New(
+		WithRoute("/data", primary,
+			WithCondition(BackendFreeAbove(primary, 100)),
+			WithPriority(10)),
+		WithRoute("/data", overflow),
+		WithStatusInterval(30*time.Second),
+		WithProbing(time.Minute, OpAll),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	fs.status.clock = ClockFunc(func() time.Time { return now })
+
+	if err := fs.MkdirAll("/data", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	writeTestFile(t, fs, "/data/a.txt", "a")
+
+	// The primary fills up and new files go to the overflow
+	primary.status.FreeBytes = 50
+	now = now.Add(time.Minute)
+	writeTestFile(t, fs, "/data/b.txt", "b")
+	if _, err := mem.Stat("/data/a.txt"); err != nil {
+		t.Errorf("a.txt not on the primary: %v", err)
+	}
+	if _, err := overflow.Stat("/data/b.txt"); err != nil {
+		t.Errorf("b.txt not on the overflow: %v", err)
+	}
+
+	// Files on the full primary stay readable and writable
+	if data, err := fs.ReadFile("/data/a.txt"); err != nil || string(data) != "a" {
+		t.Errorf("ReadFile() = %q, %v, want the file on the primary", data, err)
+	}
+	writeTestFile(t, fs, "/data/a.txt", "updated")
+	if data, err := mem.ReadFile("/data/a.txt"); err != nil || string(data) != "updated" {
+		t.Errorf("primary = %q, %v, want the file updated in place", data, err)
+	}
+	if _, err := overflow.Stat("/data/a.txt"); err == nil {
+		t.Error("update copied the file to the overflow")
+	}
+
+	entries, err := fs.ReadDir("/data")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("ReadDir() = %d entries, want both files", len(entries))
+	}
+}
@@ -1,7 +1,12 @@
 package switchfs
 
 import (
+	"fmt"
 	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,6 +16,19 @@ type RouteCondition interface {
 	Evaluate(path string, info os.FileInfo) bool
 }
 
+// ConditionFunc adapts a function to the RouteCondition interface
+type ConditionFunc func(path string, info os.FileInfo) bool
+
+// Evaluate returns f(path, info)
+func (f ConditionFunc) Evaluate(path string, info os.FileInfo) bool {
+	return f(path, info)
+}
+
+// String returns the name of the function
+func (f ConditionFunc) String() string {
+	return "ConditionFunc(" + funcName(f) + ")"
+}
+
 // PathRewriter rewrites/transforms paths for a route
 type PathRewriter interface {
 	// Rewrite transforms a path according to route rules
@@ -41,6 +59,32 @@ func (c *sizeCondition) Evaluate(path string, info os.FileInfo) bool {
 	return true
 }
 
+func (c *sizeCondition) String() string {
+	switch {
+	case c.maxSize == 0:
+		return fmt.Sprintf("MinSize(%s)", formatBytes(c.minSize))
+	case c.minSize == 0:
+		return fmt.Sprintf("MaxSize(%s)", formatBytes(c.maxSize))
+	default:
+		return fmt.Sprintf("SizeRange(%s, %s)", formatBytes(c.minSize), formatBytes(c.maxSize))
+	}
+}
+
+// formatBytes writes a byte count in the largest binary unit dividing it
+// exactly, such as 1MiB
+func formatBytes(n int64) string {
+	units := []string{"KiB", "MiB", "GiB", "TiB", "PiB"}
+	unit := ""
+	for _, u := range units {
+		if n == 0 || n%1024 != 0 {
+			break
+		}
+		n /= 1024
+		unit = u
+	}
+	return fmt.Sprintf("%d%s", n, unit)
+}
+
 // MinSize creates a condition that matches files >= minSize bytes
 func MinSize(bytes int64) RouteCondition {
 	return &sizeCondition{minSize: bytes}
@@ -80,6 +124,17 @@ func (c *timeCondition) Evaluate(path string, info os.FileInfo) bool {
 	return true
 }
 
+func (c *timeCondition) String() string {
+	switch {
+	case c.newerThan == nil:
+		return fmt.Sprintf("OlderThan(%s)", c.olderThan.Format(time.RFC3339))
+	case c.olderThan == nil:
+		return fmt.Sprintf("NewerThan(%s)", c.newerThan.Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("ModifiedBetween(%s, %s)", c.newerThan.Format(time.RFC3339), c.olderThan.Format(time.RFC3339))
+	}
+}
+
 // OlderThan creates a condition that matches files modified before the given time
 func OlderThan(t time.Time) RouteCondition {
 	return &timeCondition{olderThan: &t}
@@ -111,6 +166,13 @@ func (c *directoryCondition) Evaluate(path string, info os.FileInfo) bool {
 	return !info.IsDir()
 }
 
+func (c *directoryCondition) String() string {
+	if c.directoriesOnly {
+		return "DirectoriesOnly()"
+	}
+	return "FilesOnly()"
+}
+
 // DirectoriesOnly creates a condition that matches only directories
 func DirectoriesOnly() RouteCondition {
 	return &directoryCondition{directoriesOnly: true}
@@ -121,6 +183,305 @@ func FilesOnly() RouteCondition {
 	return &directoryCondition{directoriesOnly: false}
 }
 
+// extensionCondition matches files by extension
+type extensionCondition struct {
+	suffixes []string
+}
+
+func (c *extensionCondition) Evaluate(p string, info os.FileInfo) bool {
+	name := strings.ToLower(path.Base(p))
+	for _, suffix := range c.suffixes {
+		if len(name) > len(suffix) && strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *extensionCondition) String() string {
+	exts := make([]string, len(c.suffixes))
+	for i, suffix := range c.suffixes {
+		exts[i] = strings.TrimPrefix(suffix, ".")
+	}
+	return "HasExtension(" + quoteAll(exts) + ")"
+}
+
+// HasExtension creates a condition that matches paths ending in any of the
+// given extensions, compared case-insensitively. Extensions may be given with
+// or without the leading dot, and may be compound such as "tar.gz".
+func HasExtension(exts ...string) RouteCondition {
+	suffixes := make([]string, len(exts))
+	for i, ext := range exts {
+		suffixes[i] = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+	}
+	return &extensionCondition{suffixes: suffixes}
+}
+
+// nameGlobCondition matches the base name of a path against a glob
+type nameGlobCondition struct {
+	pattern string
+}
+
+func (c *nameGlobCondition) Evaluate(p string, info os.FileInfo) bool {
+	matched, _ := path.Match(c.pattern, path.Base(p))
+	return matched
+}
+
+func (c *nameGlobCondition) String() string {
+	return fmt.Sprintf("NameGlob(%q)", c.pattern)
+}
+
+// NameGlob creates a condition that matches paths whose base name matches a
+// glob pattern, such as "report-*.csv"
+func NameGlob(pattern string) (RouteCondition, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, ErrInvalidPattern
+	}
+	return &nameGlobCondition{pattern: pattern}, nil
+}
+
+// nameRegexCondition matches the base name of a path against a regular
+// expression
+type nameRegexCondition struct {
+	re *regexp.Regexp
+}
+
+func (c *nameRegexCondition) Evaluate(p string, info os.FileInfo) bool {
+	return c.re.MatchString(path.Base(p))
+}
+
+func (c *nameRegexCondition) String() string {
+	return fmt.Sprintf("NameRegex(%q)", c.re.String())
+}
+
+// NameRegex creates a condition that matches paths whose base name matches a
+// regular expression
+func NameRegex(expr string) (RouteCondition, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, ErrInvalidPattern
+	}
+	return &nameRegexCondition{re: re}, nil
+}
+
+// depthCondition matches paths by their number of components
+type depthCondition struct {
+	minDepth int
+	maxDepth int
+}
+
+func (c *depthCondition) Evaluate(p string, info os.FileInfo) bool {
+	depth := pathDepth(p)
+
+	if depth < c.minDepth {
+		return false
+	}
+
+	if c.maxDepth > 0 && depth > c.maxDepth {
+		return false
+	}
+
+	return true
+}
+
+func (c *depthCondition) String() string {
+	return fmt.Sprintf("PathDepth(%d, %d)", c.minDepth, c.maxDepth)
+}
+
+// pathDepth counts the components of a path: "/" has depth 0 and
+// "/a/b.txt" depth 2
+func pathDepth(p string) int {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	if p == "" {
+		return 0
+	}
+	return strings.Count(p, "/") + 1
+}
+
+// PathDepth creates a condition that matches paths with between minDepth and
+// maxDepth components, where "/a/b.txt" has depth 2. A maxDepth of zero
+// leaves the depth unbounded above.
+func PathDepth(minDepth, maxDepth int) RouteCondition {
+	return &depthCondition{minDepth: minDepth, maxDepth: maxDepth}
+}
+
+// hiddenCondition matches hidden paths
+type hiddenCondition struct{}
+
+func (c *hiddenCondition) Evaluate(p string, info os.FileInfo) bool {
+	for _, name := range strings.Split(path.Clean("/"+p), "/") {
+		if strings.HasPrefix(name, ".") && name != "." && name != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *hiddenCondition) String() string {
+	return "Hidden()"
+}
+
+// Hidden creates a condition that matches hidden paths: those whose name, or
+// the name of any parent directory, begins with a dot
+func Hidden() RouteCondition {
+	return &hiddenCondition{}
+}
+
+// modeCondition matches files by their mode bits
+type modeCondition struct {
+	all     os.FileMode
+	any     os.FileMode
+	regular bool
+}
+
+func (c *modeCondition) Evaluate(p string, info os.FileInfo) bool {
+	if info == nil {
+		return true // Can't evaluate, assume match
+	}
+
+	mode := info.Mode()
+
+	if c.regular && !mode.IsRegular() {
+		return false
+	}
+
+	if mode&c.all != c.all {
+		return false
+	}
+
+	if c.any != 0 && mode&c.any == 0 {
+		return false
+	}
+
+	return true
+}
+
+func (c *modeCondition) String() string {
+	switch *c {
+	case modeCondition{any: 0o111, regular: true}:
+		return "Executable()"
+	case modeCondition{all: 0o004}:
+		return "WorldReadable()"
+	case modeCondition{all: 0o002}:
+		return "WorldWritable()"
+	case modeCondition{all: os.ModeSymlink}:
+		return "Symlink()"
+	case modeCondition{all: os.ModeNamedPipe}:
+		return "NamedPipe()"
+	case modeCondition{all: os.ModeSocket}:
+		return "Socket()"
+	case modeCondition{all: os.ModeDevice}:
+		return "Device()"
+	case modeCondition{any: c.any}:
+		return fmt.Sprintf("HasAnyMode(%v)", c.any)
+	default:
+		return fmt.Sprintf("HasMode(%v)", c.all)
+	}
+}
+
+// HasMode creates a condition that matches files with all the given mode
+// bits set, which may combine permission, special and type bits such as
+// 0o004, os.ModeSetuid or os.ModeSymlink
+func HasMode(bits os.FileMode) RouteCondition {
+	return &modeCondition{all: bits}
+}
+
+// HasAnyMode creates a condition that matches files with at least one of the
+// given mode bits set
+func HasAnyMode(bits os.FileMode) RouteCondition {
+	return &modeCondition{any: bits}
+}
+
+// Executable creates a condition that matches regular files with any execute
+// bit set
+func Executable() RouteCondition {
+	return &modeCondition{any: 0o111, regular: true}
+}
+
+// WorldReadable creates a condition that matches files anyone may read
+func WorldReadable() RouteCondition {
+	return &modeCondition{all: 0o004}
+}
+
+// WorldWritable creates a condition that matches files anyone may write
+func WorldWritable() RouteCondition {
+	return &modeCondition{all: 0o002}
+}
+
+// Symlink creates a condition that matches symbolic links. Only information
+// from Lstat describes a link rather than its target.
+func Symlink() RouteCondition {
+	return &modeCondition{all: os.ModeSymlink}
+}
+
+// NamedPipe creates a condition that matches named pipes (FIFOs)
+func NamedPipe() RouteCondition {
+	return &modeCondition{all: os.ModeNamedPipe}
+}
+
+// Socket creates a condition that matches Unix domain sockets
+func Socket() RouteCondition {
+	return &modeCondition{all: os.ModeSocket}
+}
+
+// Device creates a condition that matches block and character devices
+func Device() RouteCondition {
+	return &modeCondition{all: os.ModeDevice}
+}
+
+// ownerCondition matches files by owner or group
+type ownerCondition struct {
+	ids   []int
+	group bool
+}
+
+func (c *ownerCondition) Evaluate(p string, info os.FileInfo) bool {
+	if info == nil {
+		return true // Can't evaluate, assume match
+	}
+
+	uid, gid, ok := ownership(info.Sys())
+	if !ok {
+		return true // Ownership not exposed, assume match
+	}
+
+	id := uid
+	if c.group {
+		id = gid
+	}
+	for _, want := range c.ids {
+		if id == want {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ownerCondition) String() string {
+	ids := make([]string, len(c.ids))
+	for i, id := range c.ids {
+		ids[i] = strconv.Itoa(id)
+	}
+	if c.group {
+		return "InGroup(" + strings.Join(ids, ", ") + ")"
+	}
+	return "OwnedBy(" + strings.Join(ids, ", ") + ")"
+}
+
+// OwnedBy creates a condition that matches files owned by any of the given
+// user IDs. The owner is read from FileInfo.Sys() when it is a pointer to a
+// struct with Uid and Gid fields, such as *syscall.Stat_t; like other
+// conditions, it matches when the owner cannot be determined.
+func OwnedBy(uids ...int) RouteCondition {
+	return &ownerCondition{ids: uids}
+}
+
+// InGroup creates a condition that matches files whose group is any of the
+// given group IDs, read like OwnedBy reads the owner
+func InGroup(gids ...int) RouteCondition {
+	return &ownerCondition{ids: gids, group: true}
+}
+
 // andCondition combines multiple conditions with AND logic
 type andCondition struct {
 	conditions []RouteCondition
@@ -135,6 +496,10 @@ func (c *andCondition) Evaluate(path string, info os.FileInfo) bool {
 	return true
 }
 
+func (c *andCondition) String() string {
+	return "And(" + joinConditions(c.conditions) + ")"
+}
+
 // And combines multiple conditions - all must be true
 func And(conditions ...RouteCondition) RouteCondition {
 	return &andCondition{conditions: conditions}
@@ -154,6 +519,10 @@ func (c *orCondition) Evaluate(path string, info os.FileInfo) bool {
 	return false
 }
 
+func (c *orCondition) String() string {
+	return "Or(" + joinConditions(c.conditions) + ")"
+}
+
 // Or combines multiple conditions - at least one must be true
 func Or(conditions ...RouteCondition) RouteCondition {
 	return &orCondition{conditions: conditions}
@@ -168,7 +537,38 @@ func (c *notCondition) Evaluate(path string, info os.FileInfo) bool {
 	return !c.condition.Evaluate(path, info)
 }
 
+func (c *notCondition) String() string {
+	return "Not(" + describe(c.condition) + ")"
+}
+
 // Not inverts a condition
 func Not(condition RouteCondition) RouteCondition {
 	return &notCondition{condition: condition}
 }
+
+// describe returns the String of a condition or rewriter, or its type for
+// custom implementations without one
+func describe(v any) string {
+	if s, ok := v.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// joinConditions describes a list of conditions
+func joinConditions(conds []RouteCondition) string {
+	parts := make([]string, len(conds))
+	for i, cond := range conds {
+		parts[i] = describe(cond)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// quoteAll quotes and joins a list of strings
+func quoteAll(strs []string) string {
+	quoted := make([]string, len(strs))
+	for i, s := range strs {
+		quoted[i] = strconv.Quote(s)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -910,3 +910,217 @@ func TestTimeConditionsWithRouting(t *testing.T) {
 		}
 	})
 }
+
+func TestHasExtension(t *testing.T) {
+	cond := HasExtension("jpg", ".PNG", "tar.gz")
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/photos/cat.jpg", true},
+		{"/photos/CAT.JPG", true},
+		{"/photos/dog.png", true},
+		{"/backups/site.tar.gz", true},
+		{"/backups/site.gz", false},
+		{"/docs/readme.md", false},
+		{"/photos/jpg", false},
+		{"/photos/.jpg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := cond.Evaluate(tt.path, nil); got != tt.want {
+				t.Errorf("HasExtension().Evaluate(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameGlobAndRegex(t *testing.T) {
+	glob, err := NameGlob("report-*.csv")
+	if err != nil {
+		t.Fatalf("NameGlob() error = %v", err)
+	}
+	re, err := NameRegex(`^IMG_\d{4}\.`)
+	if err != nil {
+		t.Fatalf("NameRegex() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		cond RouteCondition
+		path string
+		want bool
+	}{
+		{"glob matches base name", glob, "/data/2024/report-q1.csv", true},
+		{"glob ignores directories", glob, "/report-q1.csv/data.txt", false},
+		{"glob mismatch", glob, "/data/summary.csv", false},
+		{"regex matches base name", re, "/camera/IMG_0042.jpg", true},
+		{"regex mismatch", re, "/camera/IMG_42.jpg", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.Evaluate(tt.path, nil); got != tt.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NameGlob("[a-"); err != ErrInvalidPattern {
+		t.Errorf("NameGlob() invalid error = %v, want ErrInvalidPattern", err)
+	}
+	if _, err := NameRegex("(["); err != ErrInvalidPattern {
+		t.Errorf("NameRegex() invalid error = %v, want ErrInvalidPattern", err)
+	}
+}
+
+func TestPathDepth(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max int
+		path     string
+		want     bool
+	}{
+		{"root has depth 0", 0, 0, "/", true},
+		{"within range", 2, 3, "/a/b.txt", true},
+		{"too shallow", 2, 3, "/a", false},
+		{"too deep", 2, 3, "/a/b/c/d.txt", false},
+		{"unbounded above", 1, 0, "/a/b/c/d/e/f", true},
+		{"trailing slash ignored", 2, 2, "/a/b/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PathDepth(tt.min, tt.max).Evaluate(tt.path, nil); got != tt.want {
+				t.Errorf("PathDepth(%d, %d).Evaluate(%q) = %v, want %v", tt.min, tt.max, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHidden(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/home/user/.bashrc", true},
+		{"/repo/.git/objects/ab/cdef", true},
+		{"/home/user/notes.txt", false},
+		{"/home/user/../notes.txt", false},
+		{"/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := Hidden().Evaluate(tt.path, nil); got != tt.want {
+				t.Errorf("Hidden().Evaluate(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameConditions_Composed(t *testing.T) {
+	// Large images that are not hidden
+	cond := And(HasExtension("jpg", "png"), MinSize(1000), Not(Hidden()))
+
+	if !cond.Evaluate("/photos/cat.jpg", &mockFileInfo{size: 5000}) {
+		t.Error("large visible image should match")
+	}
+	if cond.Evaluate("/photos/cat.jpg", &mockFileInfo{size: 10}) {
+		t.Error("small image should not match")
+	}
+	if cond.Evaluate("/photos/.thumbs/cat.jpg", &mockFileInfo{size: 5000}) {
+		t.Error("hidden image should not match")
+	}
+}
+
+func TestModeConditions(t *testing.T) {
+	file := &mockFileInfo{mode: 0o644}
+	script := &mockFileInfo{mode: 0o755}
+	dir := &mockFileInfo{mode: os.ModeDir | 0o755, isDir: true}
+	private := &mockFileInfo{mode: 0o600}
+	shared := &mockFileInfo{mode: 0o666}
+	link := &mockFileInfo{mode: os.ModeSymlink | 0o777}
+	pipe := &mockFileInfo{mode: os.ModeNamedPipe | 0o644}
+	setuid := &mockFileInfo{mode: os.ModeSetuid | 0o755}
+
+	tests := []struct {
+		name string
+		cond RouteCondition
+		info os.FileInfo
+		want bool
+	}{
+		{"executable script", Executable(), script, true},
+		{"executable plain file", Executable(), file, false},
+		{"executable directory", Executable(), dir, false},
+		{"world-readable", WorldReadable(), file, true},
+		{"world-readable private", WorldReadable(), private, false},
+		{"world-writable", WorldWritable(), shared, true},
+		{"world-writable plain file", WorldWritable(), file, false},
+		{"symlink", Symlink(), link, true},
+		{"symlink plain file", Symlink(), file, false},
+		{"named pipe", NamedPipe(), pipe, true},
+		{"named pipe symlink", NamedPipe(), link, false},
+		{"socket", Socket(), pipe, false},
+		{"device", Device(), &mockFileInfo{mode: os.ModeDevice | os.ModeCharDevice}, true},
+		{"has mode", HasMode(os.ModeSetuid | 0o100), setuid, true},
+		{"has mode partial", HasMode(os.ModeSetuid | 0o100), script, false},
+		{"has any mode", HasAnyMode(os.ModeSetuid | os.ModeSetgid), setuid, true},
+		{"has any mode none", HasAnyMode(os.ModeSetuid | os.ModeSetgid), script, false},
+		{"nil info", Executable(), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.Evaluate("/file", tt.info); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// ownerSys mirrors the ownership fields of syscall.Stat_t
+type ownerSys struct {
+	Uid uint32
+	Gid uint32
+}
+
+func TestOwnerConditions(t *testing.T) {
+	root := &sysFileInfo{sys: &ownerSys{Uid: 0, Gid: 0}}
+	alice := &sysFileInfo{sys: &ownerSys{Uid: 1000, Gid: 100}}
+	unknown := &mockFileInfo{}
+
+	tests := []struct {
+		name string
+		cond RouteCondition
+		info os.FileInfo
+		want bool
+	}{
+		{"owned by root", OwnedBy(0), root, true},
+		{"owned by root, alice's file", OwnedBy(0), alice, false},
+		{"owned by any", OwnedBy(0, 1000), alice, true},
+		{"in group", InGroup(100), alice, true},
+		{"in other group", InGroup(100), root, false},
+		{"ownership not exposed", OwnedBy(0), unknown, true},
+		{"nil info", InGroup(0), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.Evaluate("/file", tt.info); got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Sensitive content: executables or files owned by root
+	sensitive := Or(Executable(), OwnedBy(0))
+	if !sensitive.Evaluate("/bin/tool", &sysFileInfo{mockFileInfo: mockFileInfo{mode: 0o755}, sys: &ownerSys{Uid: 1000}}) {
+		t.Error("executable should be sensitive")
+	}
+	if sensitive.Evaluate("/home/notes.txt", &sysFileInfo{mockFileInfo: mockFileInfo{mode: 0o644}, sys: &ownerSys{Uid: 1000}}) {
+		t.Error("user's plain file should not be sensitive")
+	}
+}
@@ -0,0 +1,430 @@
+package switchfs
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/absfs/absfs"
+)
+
+// sniffLen is the number of leading bytes content conditions inspect, the
+// amount net/http.DetectContentType considers
+const sniffLen = 512
+
+// ContentCondition is a RouteCondition that also inspects the first bytes of
+// a file. SwitchFS supplies them from the existing file when it is read, and
+// from the first write when it is created.
+type ContentCondition interface {
+	RouteCondition
+
+	// EvaluateContent returns true if the condition is met for the given
+	// file info and leading bytes. Info is nil for files being created.
+	EvaluateContent(path string, info os.FileInfo, head []byte) bool
+}
+
+// ContentDetector returns the MIME type of content from its leading bytes
+type ContentDetector func(head []byte) string
+
+// contentTypeCondition matches files by the MIME type of their content
+type contentTypeCondition struct {
+	detect ContentDetector
+	types  []string
+	custom bool
+}
+
+// Evaluate cannot see the content, so it only rules out directories
+func (c *contentTypeCondition) Evaluate(p string, info os.FileInfo) bool {
+	if info == nil {
+		return true // Can't evaluate, assume match
+	}
+	return !info.IsDir()
+}
+
+func (c *contentTypeCondition) EvaluateContent(p string, info os.FileInfo, head []byte) bool {
+	if info != nil && !info.Mode().IsRegular() {
+		return false
+	}
+
+	detected := mediaType(c.detect(head))
+	for _, want := range c.types {
+		if want == detected {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(want, "/*"); ok && strings.HasPrefix(detected, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *contentTypeCondition) String() string {
+	if c.custom {
+		return "ContentTypeDetector(" + funcName(c.detect) + ", " + quoteAll(c.types) + ")"
+	}
+	return "ContentType(" + quoteAll(c.types) + ")"
+}
+
+// mediaType lowercases a MIME type and drops its parameters
+func mediaType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	mt, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// ContentType creates a condition that matches files whose content, sniffed
+// with net/http.DetectContentType, has one of the given MIME types. Types
+// are compared without parameters and case-insensitively, and may end in
+// "/*" to match a whole class, such as "image/*".
+func ContentType(types ...string) RouteCondition {
+	return newContentTypeCondition(http.DetectContentType, false, types)
+}
+
+// ContentTypeDetector creates a condition like ContentType that sniffs
+// content with a custom detector
+func ContentTypeDetector(detect ContentDetector, types ...string) RouteCondition {
+	return newContentTypeCondition(detect, true, types)
+}
+
+// newContentTypeCondition builds a content type condition with normalized
+// types
+func newContentTypeCondition(detect ContentDetector, custom bool, types []string) *contentTypeCondition {
+	normalized := make([]string, len(types))
+	for i, t := range types {
+		normalized[i] = mediaType(t)
+	}
+	return &contentTypeCondition{detect: detect, types: normalized, custom: custom}
+}
+
+func (c *andCondition) EvaluateContent(path string, info os.FileInfo, head []byte) bool {
+	for _, cond := range c.conditions {
+		if !evaluateContent(cond, path, info, head) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *orCondition) EvaluateContent(path string, info os.FileInfo, head []byte) bool {
+	for _, cond := range c.conditions {
+		if evaluateContent(cond, path, info, head) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *notCondition) EvaluateContent(path string, info os.FileInfo, head []byte) bool {
+	return !evaluateContent(c.condition, path, info, head)
+}
+
+// evaluateContent evaluates a condition with a file's leading bytes, falling
+// back to Evaluate for conditions that do not inspect content
+func evaluateContent(cond RouteCondition, path string, info os.FileInfo, head []byte) bool {
+	if cc, ok := cond.(ContentCondition); ok {
+		return cc.EvaluateContent(path, info, head)
+	}
+	return cond.Evaluate(path, info)
+}
+
+// usesContent reports whether a condition inspects content, looking through
+// And, Or and Not
+func usesContent(cond RouteCondition) bool {
+	return anyLeaf(cond, func(leaf RouteCondition) bool {
+		_, ok := leaf.(ContentCondition)
+		return ok
+	})
+}
+
+// sniff reads the leading bytes of a regular file on a backend
+func sniff(backend absfs.FileSystem, name string) (os.FileInfo, []byte, bool) {
+	info, err := backend.Stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return info, nil, false
+	}
+	f, err := backend.Open(name)
+	if err != nil {
+		return info, nil, false
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return info, nil, false
+	}
+	return info, head[:n], true
+}
+
+// holdsContent reports whether a route's backend holds a regular file at a
+// path whose content meets the route's condition
+func holdsContent(route *Route, name string) bool {
+	info, head, ok := sniff(route.backendFor(OpOpen, name), name)
+	return ok && evaluateContent(route.Condition, name, info, head)
+}
+
+// placeByContent finds the route a new file goes to given its leading
+// bytes: the first content route whose condition they meet, else the first
+// route without a content condition. It returns nil when the default
+// backend receives the file.
+func (fs *SwitchFS) placeByContent(op Operation, name string, head []byte) *Route {
+	for _, route := range fs.routesFor(op, name) {
+		if !fs.admits(fs.ctx, route, name) {
+			continue
+		}
+		if !route.sniffs || evaluateContent(route.Condition, name, nil, head) {
+			return route
+		}
+	}
+	return nil
+}
+
+// contentRoutes returns every route a file under a path routed by content
+// may be placed on: each content route admitting the call, up to the first
+// route without a content condition, or else the default backend as a nil
+// route. It returns nil when the path is not routed by content.
+func (fs *SwitchFS) contentRoutes(op Operation, name string) []*Route {
+	var routes []*Route
+	for _, route := range fs.routesFor(op, name) {
+		if !fs.admits(fs.ctx, route, name) {
+			continue
+		}
+		routes = append(routes, route)
+		if !route.sniffs {
+			break
+		}
+	}
+	if len(routes) == 0 || !routes[0].sniffs {
+		return nil
+	}
+	if routes[len(routes)-1].sniffs && fs.defaultFS != nil {
+		routes = append(routes, nil)
+	}
+	return routes
+}
+
+// createByContent returns a file that defers choosing its backend until its
+// first bytes are written, for creates of new files on paths routed by
+// content. It reports false when the path is not routed by content or the
+// file already exists, leaving the caller to open it normally. Creates no
+// route could accept, because every candidate is read-only or out of file
+// quota, and exclusive creates of a path any candidate holds fail at once.
+func (fs *SwitchFS) createByContent(name string, flag int, perm os.FileMode) (absfs.File, bool, error) {
+	op := openOperation(flag)
+	if route, err := fs.routeOperation(op, name); err != nil || !route.selective() {
+		return nil, false, nil
+	}
+	routes := fs.contentRoutes(op, name)
+	if routes == nil {
+		return nil, false, nil
+	}
+
+	// Existing files are opened where they are
+	if _, backend, err := fs.lookup(op, name); err == nil {
+		if _, err := backend.Stat(name); err == nil {
+			return nil, false, nil
+		}
+	}
+
+	// The file is only created once its backend is known, so exclusive
+	// creates check every backend it may be placed on now
+	if flag&os.O_EXCL != 0 {
+		for _, route := range routes {
+			backend, err := fs.backendOf(route, op, name)
+			if err != nil {
+				continue
+			}
+			if _, err := lstatBackend(backend, name); err == nil {
+				return nil, true, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+			}
+		}
+	}
+
+	var rejected error
+	for _, route := range routes {
+		if err := fs.admitsCreate(route, name); err != nil {
+			if rejected == nil {
+				rejected = err
+			}
+			continue
+		}
+		return &sniffFile{fs: fs, name: name, flag: flag, perm: perm}, true, nil
+	}
+	return nil, true, rejected
+}
+
+// admitsCreate reports why a route would reject creating a file, if it would
+func (fs *SwitchFS) admitsCreate(route *Route, name string) error {
+	if route == nil {
+		return nil
+	}
+	if route.ReadOnly {
+		return &os.PathError{Op: OpCreate.String(), Path: name, Err: os.ErrPermission}
+	}
+	if route.quota != nil {
+		if err := route.quota.check(0, 1); err != nil {
+			return quotaError("open", name, err)
+		}
+	}
+	return nil
+}
+
+// createWithHead creates a file on the route its leading bytes select and
+// writes them to it
+func (fs *SwitchFS) createWithHead(name string, flag int, perm os.FileMode, head []byte) (absfs.File, error) {
+	op := openOperation(flag)
+	route := fs.placeByContent(op, name, head)
+	backend, err := fs.backendOf(route, op, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.throttle(fs.ctx, route, op, name); err != nil {
+		return nil, err
+	}
+	if fs.probes != nil {
+		fs.probes.invalidate(op, name)
+	}
+
+	f, err := fs.openOn(route, backend, name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if len(head) > 0 {
+		if _, err := f.Write(head); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// sniffFile is a file being created on a path routed by content. Writes are
+// buffered until sniffLen bytes are known or another method is called, then
+// the file is created on the backend the buffered bytes select.
+type sniffFile struct {
+	fs   *SwitchFS
+	name string
+	flag int
+	perm os.FileMode
+
+	head []byte
+	file absfs.File
+	err  error
+}
+
+// open creates the underlying file once
+func (f *sniffFile) open() error {
+	if f.file == nil && f.err == nil {
+		f.file, f.err = f.fs.createWithHead(f.name, f.flag, f.perm, f.head)
+		f.head = nil
+	}
+	return f.err
+}
+
+func (f *sniffFile) Name() string {
+	return f.name
+}
+
+func (f *sniffFile) Write(p []byte) (int, error) {
+	if f.file == nil && f.err == nil {
+		f.head = append(f.head, p...)
+		if len(f.head) < sniffLen {
+			return len(p), nil
+		}
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.file.Write(p)
+}
+
+func (f *sniffFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *sniffFile) WriteAt(p []byte, off int64) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.file.WriteAt(p, off)
+}
+
+func (f *sniffFile) Read(p []byte) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.file.Read(p)
+}
+
+func (f *sniffFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.file.ReadAt(p, off)
+}
+
+func (f *sniffFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	return f.file.Seek(offset, whence)
+}
+
+func (f *sniffFile) Truncate(size int64) error {
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.file.Truncate(size)
+}
+
+func (f *sniffFile) Sync() error {
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+func (f *sniffFile) Stat() (os.FileInfo, error) {
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f.file.Stat()
+}
+
+func (f *sniffFile) Readdir(n int) ([]os.FileInfo, error) {
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f.file.Readdir(n)
+}
+
+func (f *sniffFile) Readdirnames(n int) ([]string, error) {
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f.file.Readdirnames(n)
+}
+
+func (f *sniffFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f.file.ReadDir(n)
+}
+
+// Close creates the file if no method has yet, then closes it
+func (f *sniffFile) Close() error {
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.file.Close()
+}
@@ -0,0 +1,300 @@
+package switchfs
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/absfs/memfs"
+)
+
+var (
+	pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	pdfHeader = "%PDF-1.7\n"
+)
+
+func TestContentTypeCondition(t *testing.T) {
+	tests := []struct {
+		name  string
+		cond  RouteCondition
+		head  string
+		match bool
+	}{
+		{"exact type", ContentType("image/png"), pngHeader, true},
+		{"class", ContentType("image/*"), pngHeader, true},
+		{"case and parameters", ContentType("Text/Plain; charset=utf-8"), "hello", true},
+		{"other type", ContentType("image/*"), pdfHeader, false},
+		{"any of several", ContentType("image/*", "application/pdf"), pdfHeader, true},
+		{"not", Not(ContentType("image/*")), pdfHeader, true},
+		{"and", And(HasExtension("png"), ContentType("image/*")), pngHeader, true},
+		{"custom detector", ContentTypeDetector(func(head []byte) string {
+			if strings.HasPrefix(string(head), "PAR1") {
+				return "application/vnd.apache.parquet"
+			}
+			return "application/octet-stream"
+		}, "application/vnd.apache.parquet"), "PAR1....", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluateContent(tt.cond, "/x.png", nil, []byte(tt.head)); got != tt.match {
+				t.Errorf("evaluateContent() = %v, want %v", got, tt.match)
+			}
+		})
+	}
+
+	t.Run("directories never match", func(t *testing.T) {
+		mem, _ := memfs.NewFS()
+		mem.MkdirAll("/dir", 0755)
+		info, _ := mem.Stat("/dir")
+		if evaluateContent(ContentType("text/plain"), "/dir", info, nil) {
+			t.Error("directory matched a content condition")
+		}
+	})
+
+	t.Run("uses content", func(t *testing.T) {
+		if !usesContent(Or(MinSize(1), Not(ContentType("image/*")))) {
+			t.Error("usesContent() = false for nested content condition")
+		}
+		if usesContent(And(MinSize(1), FilesOnly())) {
+			t.Error("usesContent() = true without content condition")
+		}
+	})
+}
+
+func TestContentRouting(t *testing.T) {
+	def, _ := memfs.NewFS()
+	media, _ := memfs.NewFS()
+	docs, _ := memfs.NewFS()
+
+	fs, err := New(
+		WithDefault(def),
+		WithRoute("/uploads", media, WithCondition(ContentType("image/*")), WithPriority(10)),
+		WithRoute("/uploads", docs, WithCondition(ContentType("application/pdf")), WithPriority(5)),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := fs.MkdirAll("/uploads", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+
+	// create writes a file through the SwitchFS in small pieces
+	create := func(t *testing.T, name, content string) {
+		t.Helper()
+		f, err := fs.Create(name)
+		if err != nil {
+			t.Fatalf("Create(%s) error = %v", name, err)
+		}
+		for len(content) > 0 {
+			n := min(len(content), 4)
+			if _, err := f.Write([]byte(content[:n])); err != nil {
+				t.Fatalf("Write(%s) error = %v", name, err)
+			}
+			content = content[n:]
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close(%s) error = %v", name, err)
+		}
+	}
+
+	png := pngHeader + strings.Repeat("x", 1000)
+	create(t, "/uploads/photo.png", png)
+	create(t, "/uploads/paper.pdf", pdfHeader)
+	create(t, "/uploads/notes.txt", "plain text")
+
+	for _, tt := range []struct {
+		name    string
+		content string
+		holder  *memfs.FileSystem
+	}{
+		{"/uploads/photo.png", png, media},
+		{"/uploads/paper.pdf", pdfHeader, docs},
+		{"/uploads/notes.txt", "plain text", def},
+	} {
+		for _, backend := range []*memfs.FileSystem{def, media, docs} {
+			_, err := backend.Stat(tt.name)
+			if held := err == nil; held != (backend == tt.holder) {
+				t.Errorf("%s on wrong backend: held = %v", tt.name, held)
+			}
+		}
+
+		data, err := fs.ReadFile(tt.name)
+		if err != nil || string(data) != tt.content {
+			t.Errorf("ReadFile(%s) = %d bytes, %v", tt.name, len(data), err)
+		}
+		info, err := fs.Stat(tt.name)
+		if err != nil || info.Size() != int64(len(tt.content)) {
+			t.Errorf("Stat(%s) = %v, %v", tt.name, info, err)
+		}
+	}
+
+	t.Run("existing file opened in place", func(t *testing.T) {
+		f, err := fs.OpenFile("/uploads/paper.pdf", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		f.Write([]byte("%%EOF\n"))
+		f.Close()
+
+		data, _ := docs.ReadFile("/uploads/paper.pdf")
+		if string(data) != pdfHeader+"%%EOF\n" {
+			t.Errorf("docs content = %q", data)
+		}
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		f, err := fs.Create("/uploads/empty")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if _, err := def.Stat("/uploads/empty"); err != nil {
+			t.Errorf("empty file not on default backend: %v", err)
+		}
+	})
+
+	t.Run("directories span backends", func(t *testing.T) {
+		for _, backend := range []*memfs.FileSystem{def, media, docs} {
+			if info, err := backend.Stat("/uploads"); err != nil || !info.IsDir() {
+				t.Errorf("MkdirAll() left a backend without /uploads: %v", err)
+			}
+		}
+
+		entries, err := fs.ReadDir("/uploads")
+		if err != nil {
+			t.Fatalf("ReadDir() error = %v", err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if got := strings.Join(names, ","); got != "empty,notes.txt,paper.pdf,photo.png" {
+			t.Errorf("ReadDir() = %s", got)
+		}
+
+		// A directory made on one backend only is still found
+		media.Mkdir("/uploads/albums", 0755)
+		if info, err := fs.Stat("/uploads/albums"); err != nil || !info.IsDir() {
+			t.Errorf("Stat() = %v, %v", info, err)
+		}
+		if err := fs.Remove("/uploads/albums"); err != nil {
+			t.Errorf("Remove() error = %v", err)
+		}
+		if _, err := media.Stat("/uploads/albums"); !os.IsNotExist(err) {
+			t.Errorf("media Stat() after Remove() error = %v", err)
+		}
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		if err := fs.Remove("/uploads/photo.png"); err != nil {
+			t.Fatalf("Remove() error = %v", err)
+		}
+		if _, err := media.Stat("/uploads/photo.png"); !os.IsNotExist(err) {
+			t.Errorf("media Stat() error = %v, want not exist", err)
+		}
+	})
+
+	t.Run("remove all", func(t *testing.T) {
+		create(t, "/uploads/photo.png", png)
+		if err := fs.RemoveAll("/uploads"); err != nil {
+			t.Fatalf("RemoveAll() error = %v", err)
+		}
+		for _, backend := range []*memfs.FileSystem{def, media, docs} {
+			if _, err := backend.Stat("/uploads"); !os.IsNotExist(err) {
+				t.Errorf("RemoveAll() left /uploads behind: %v", err)
+			}
+		}
+	})
+}
+
+func TestContentCreateChecks(t *testing.T) {
+	def, _ := memfs.NewFS()
+	media, _ := memfs.NewFS()
+	def.MkdirAll("/uploads", 0755)
+	media.MkdirAll("/uploads", 0755)
+
+	t.Run("read-only", func(t *testing.T) {
+		fs, _ := New(
+			WithRoute("/uploads", media, WithCondition(ContentType("image/*")), WithReadOnly(), WithPriority(10)),
+			WithRoute("/uploads", def, WithReadOnly()),
+		)
+		if _, err := fs.Create("/uploads/photo.png"); !os.IsPermission(err) {
+			t.Errorf("Create() error = %v, want permission error", err)
+		}
+	})
+
+	t.Run("exclusive", func(t *testing.T) {
+		def, _ := memfs.NewFS()
+		media, _ := memfs.NewFS()
+		def.MkdirAll("/uploads", 0755)
+		media.MkdirAll("/uploads", 0755)
+		fs, _ := New(
+			WithDefault(def),
+			WithRoute("/uploads", media, WithCondition(ContentType("image/*")), WithPriority(10)),
+		)
+
+		// A file the media backend holds without matching its condition is
+		// not served from there, but an exclusive create still sees it
+		writeTestFile(t, media, "/uploads/clash", "plain text")
+		if _, err := fs.OpenFile("/uploads/clash", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644); !os.IsExist(err) {
+			t.Errorf("OpenFile(O_EXCL) error = %v, want an exist error", err)
+		}
+
+		f, err := fs.OpenFile("/uploads/fresh.png", os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			t.Fatalf("OpenFile(O_EXCL) error = %v", err)
+		}
+		if _, err := f.Write([]byte(pngHeader)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if _, err := media.Stat("/uploads/fresh.png"); err == nil {
+			t.Error("short file created before Close")
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if _, err := media.Stat("/uploads/fresh.png"); err != nil {
+			t.Errorf("file not created on Close: %v", err)
+		}
+	})
+
+	t.Run("quota", func(t *testing.T) {
+		fs, _ := New(
+			WithRoute("/uploads", media, WithCondition(ContentType("image/*")), WithQuota(0, 1)),
+		)
+		writeTestFile(t, fs, "/uploads/a.png", pngHeader)
+		if _, err := fs.Create("/uploads/b.png"); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Create() error = %v, want ErrQuotaExceeded", err)
+		}
+	})
+}
+
+func TestContentRoutingCustomRouter(t *testing.T) {
+	def, _ := memfs.NewFS()
+	media, _ := memfs.NewFS()
+
+	fs, err := New(
+		WithRouter(basicRouter{NewRouter()}),
+		WithDefault(def),
+		WithRoute("/uploads", media, WithCondition(ContentType("image/*"))),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := fs.MkdirAll("/uploads", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+
+	writeTestFile(t, fs, "/uploads/photo.png", pngHeader)
+	writeTestFile(t, fs, "/uploads/notes.txt", "plain text")
+	if _, err := media.Stat("/uploads/photo.png"); err != nil {
+		t.Errorf("png not on media backend: %v", err)
+	}
+	if _, err := def.Stat("/uploads/notes.txt"); err != nil {
+		t.Errorf("text not on default backend: %v", err)
+	}
+}
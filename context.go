@@ -0,0 +1,210 @@
+package switchfs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ContextCondition is a RouteCondition that also inspects the context of a
+// call, such as the calling tenant or user. SwitchFS supplies the context
+// given to WithContext.
+type ContextCondition interface {
+	RouteCondition
+
+	// EvaluateContext returns true if the condition is met for the given
+	// call context and file info
+	EvaluateContext(ctx context.Context, path string, info os.FileInfo) bool
+}
+
+// WithContext returns a view of the SwitchFS whose calls carry ctx. Routes
+// with context conditions are chosen by evaluating them against ctx, and
+// rate-limit waits end when ctx is done. The view shares routes, backends
+// and the working directory of fs at the time of the call.
+func (fs *SwitchFS) WithContext(ctx context.Context) *SwitchFS {
+	if ctx == nil {
+		panic("switchfs: nil context")
+	}
+	view := *fs
+	view.ctx = ctx
+	return &view
+}
+
+func (c *andCondition) EvaluateContext(ctx context.Context, path string, info os.FileInfo) bool {
+	for _, cond := range c.conditions {
+		if !evaluateContext(ctx, cond, path, info) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *orCondition) EvaluateContext(ctx context.Context, path string, info os.FileInfo) bool {
+	for _, cond := range c.conditions {
+		if evaluateContext(ctx, cond, path, info) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *notCondition) EvaluateContext(ctx context.Context, path string, info os.FileInfo) bool {
+	return !evaluateContext(ctx, c.condition, path, info)
+}
+
+// evaluateContext evaluates a condition with a call context, falling back to
+// Evaluate for conditions that do not inspect the context
+func evaluateContext(ctx context.Context, cond RouteCondition, path string, info os.FileInfo) bool {
+	if cc, ok := cond.(ContextCondition); ok {
+		return cc.EvaluateContext(ctx, path, info)
+	}
+	return cond.Evaluate(path, info)
+}
+
+// usesContext reports whether a condition inspects the call context, looking
+// through And, Or and Not. Dispatch conditions reading backend status through
+// the context do not count.
+func usesContext(cond RouteCondition) bool {
+	return anyLeaf(cond, func(leaf RouteCondition) bool {
+		_, ok := leaf.(ContextCondition)
+		_, dispatched := leaf.(DispatchCondition)
+		return ok && !dispatched
+	})
+}
+
+// admits reports whether the context and dispatch conditions of a route hold
+// for a call, without file information. Conditions also see the SwitchFS's
+// backend status cache through ctx.
+func (fs *SwitchFS) admits(ctx context.Context, route *Route, path string) bool {
+	if !route.contextual && !route.dispatched {
+		return true
+	}
+	ctx = context.WithValue(ctx, statusKey{}, fs.status)
+	return evaluateContext(ctx, route.Condition, path, nil)
+}
+
+// contextValueCondition matches calls by a value stored in their context
+type contextValueCondition struct {
+	key    any
+	values []any
+}
+
+// Evaluate cannot see the context, so it assumes a match
+func (c *contextValueCondition) Evaluate(path string, info os.FileInfo) bool {
+	return true
+}
+
+func (c *contextValueCondition) EvaluateContext(ctx context.Context, path string, info os.FileInfo) bool {
+	value := ctx.Value(c.key)
+	if value == nil {
+		return false
+	}
+	if len(c.values) == 0 {
+		return true
+	}
+	if !reflect.TypeOf(value).Comparable() {
+		return false
+	}
+	for _, want := range c.values {
+		if value == want {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *contextValueCondition) String() string {
+	if _, ok := c.key.(tenantKey); ok {
+		return "Tenant(" + joinValues(c.values) + ")"
+	}
+	if len(c.values) == 0 {
+		return fmt.Sprintf("ContextValue(%T)", c.key)
+	}
+	return fmt.Sprintf("ContextValue(%T, %s)", c.key, joinValues(c.values))
+}
+
+// joinValues formats and joins a list of context values
+func joinValues(values []any) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%#v", v)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ContextValue creates a condition that matches calls whose context holds
+// one of the given values under key, or any value when none are given
+func ContextValue(key any, values ...any) RouteCondition {
+	return &contextValueCondition{key: key, values: values}
+}
+
+// tenantKey and rolesKey are the context keys of the tenant and roles of a
+// call
+type (
+	tenantKey struct{}
+	rolesKey  struct{}
+)
+
+// ContextWithTenant returns a copy of ctx carrying the calling tenant's ID
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant ID carried by ctx
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(string)
+	return tenant, ok
+}
+
+// Tenant creates a condition that matches calls made on behalf of any of
+// the given tenants
+func Tenant(tenants ...string) RouteCondition {
+	values := make([]any, len(tenants))
+	for i, tenant := range tenants {
+		values[i] = tenant
+	}
+	return &contextValueCondition{key: tenantKey{}, values: values}
+}
+
+// ContextWithRoles returns a copy of ctx carrying the roles of the caller
+func ContextWithRoles(ctx context.Context, roles ...string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+// RolesFromContext returns the roles carried by ctx
+func RolesFromContext(ctx context.Context) []string {
+	roles, _ := ctx.Value(rolesKey{}).([]string)
+	return roles
+}
+
+// roleCondition matches calls by the roles of the caller
+type roleCondition struct {
+	roles []string
+}
+
+// Evaluate cannot see the context, so it assumes a match
+func (c *roleCondition) Evaluate(path string, info os.FileInfo) bool {
+	return true
+}
+
+func (c *roleCondition) EvaluateContext(ctx context.Context, path string, info os.FileInfo) bool {
+	for _, role := range RolesFromContext(ctx) {
+		if slices.Contains(c.roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *roleCondition) String() string {
+	return "HasRole(" + quoteAll(c.roles) + ")"
+}
+
+// HasRole creates a condition that matches calls whose caller has any of the
+// given roles
+func HasRole(roles ...string) RouteCondition {
+	return &roleCondition{roles: roles}
+}
@@ -0,0 +1,133 @@
+package switchfs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/absfs/memfs"
+)
+
+func TestContextConditions(t *testing.T) {
+	type requestKey struct{}
+
+	ctx := ContextWithRoles(ContextWithTenant(context.Background(), "acme"), "reader", "auditor")
+	ctx = context.WithValue(ctx, requestKey{}, 42)
+
+	tests := []struct {
+		name string
+		cond RouteCondition
+		ctx  context.Context
+		want bool
+	}{
+		{"tenant", Tenant("acme"), ctx, true},
+		{"any tenant", Tenant("globex", "acme"), ctx, true},
+		{"other tenant", Tenant("globex"), ctx, false},
+		{"no tenant", Tenant("acme"), context.Background(), false},
+		{"role", HasRole("admin", "auditor"), ctx, true},
+		{"missing role", HasRole("admin"), ctx, false},
+		{"value", ContextValue(requestKey{}, 41, 42), ctx, true},
+		{"other value", ContextValue(requestKey{}, 7), ctx, false},
+		{"any value", ContextValue(requestKey{}), ctx, true},
+		{"absent value", ContextValue(requestKey{}), context.Background(), false},
+		{"uncomparable value", ContextValue(rolesKey{}, "reader"), ctx, false},
+		{"and", And(Tenant("acme"), HasExtension("txt")), ctx, true},
+		{"not", Not(Tenant("acme")), ctx, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluateContext(tt.ctx, tt.cond, "/data/file.txt", nil); got != tt.want {
+				t.Errorf("evaluateContext() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if tenant, ok := TenantFromContext(ctx); !ok || tenant != "acme" {
+		t.Errorf("TenantFromContext() = %q, %v", tenant, ok)
+	}
+	if !Tenant("acme").Evaluate("/data", nil) {
+		t.Error("Evaluate() without a context should assume a match")
+	}
+}
+
+func TestTenantRouting(t *testing.T) {
+	shared, _ := memfs.NewFS()
+	acme, _ := memfs.NewFS()
+	globex, _ := memfs.NewFS()
+
+	fs, err := New(
+		WithRoute("/data", acme, WithCondition(Tenant("acme")), WithPriority(10)),
+		WithRoute("/data", globex, WithCondition(Tenant("globex")), WithPriority(10)),
+		WithRoute("/data", shared),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, backend := range []*memfs.FileSystem{shared, acme, globex} {
+		backend.MkdirAll("/data", 0755)
+	}
+
+	acmeFS := fs.WithContext(ContextWithTenant(context.Background(), "acme"))
+	globexFS := fs.WithContext(ContextWithTenant(context.Background(), "globex"))
+
+	writeTestFile(t, acmeFS, "/data/a.txt", "acme")
+	writeTestFile(t, globexFS, "/data/a.txt", "globex")
+	writeTestFile(t, fs, "/data/a.txt", "shared")
+
+	for _, tt := range []struct {
+		name    string
+		backend *memfs.FileSystem
+		want    string
+	}{
+		{"acme", acme, "acme"},
+		{"globex", globex, "globex"},
+		{"shared", shared, "shared"},
+	} {
+		data, err := tt.backend.ReadFile("/data/a.txt")
+		if err != nil || string(data) != tt.want {
+			t.Errorf("%s backend = %q, %v, want %q", tt.name, data, err, tt.want)
+		}
+	}
+
+	data, err := acmeFS.ReadFile("/data/a.txt")
+	if err != nil || string(data) != "acme" {
+		t.Errorf("acme ReadFile() = %q, %v", data, err)
+	}
+
+	// Views do not change the SwitchFS they came from
+	data, err = fs.ReadFile("/data/a.txt")
+	if err != nil || string(data) != "shared" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+
+	// Renames through a view stay on the tenant's backend
+	if err := acmeFS.Rename("/data/a.txt", "/data/b.txt"); err != nil {
+		t.Fatalf("Rename() error = %v", err)
+	}
+	if data, err := acme.ReadFile("/data/b.txt"); err != nil || string(data) != "acme" {
+		t.Errorf("acme backend after Rename() = %q, %v", data, err)
+	}
+	if _, err := shared.Stat("/data/a.txt"); err != nil {
+		t.Errorf("Rename() through a view moved the shared file: %v", err)
+	}
+}
+
+func TestWithContextBoundsRateLimit(t *testing.T) {
+	mem, _ := memfs.NewFS()
+	mem.MkdirAll("/slow", 0755)
+
+	fs, err := New(WithRoute("/slow", mem, WithOpsLimit(1)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := fs.Stat("/slow"); err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := fs.WithContext(ctx).Stat("/slow"); err == nil {
+		t.Error("Stat() succeeded past the context deadline")
+	}
+}
@@ -0,0 +1,129 @@
+package switchfs
+
+// DispatchCondition is a RouteCondition that SwitchFS evaluates whenever it
+// dispatches a call, so that routes using it are chosen per call rather than
+// only by RouteWithInfo. Conditions on the clock, the path name, a backend's
+// status or a file's metadata implement it; size and time conditions on
+// absolute dates do not.
+type DispatchCondition interface {
+	RouteCondition
+
+	// NeedsFileInfo reports whether the condition inspects file
+	// information. Routes with such conditions only serve paths their
+	// backend holds, whatever the file information now says.
+	NeedsFileInfo() bool
+}
+
+// usesDispatch reports whether a condition is evaluated at dispatch, looking
+// through And, Or and Not
+func usesDispatch(cond RouteCondition) bool {
+	return anyLeaf(cond, func(leaf RouteCondition) bool {
+		_, ok := leaf.(DispatchCondition)
+		return ok
+	})
+}
+
+// inspectsFiles reports whether a condition evaluated at dispatch needs file
+// information, looking through And, Or and Not
+func inspectsFiles(cond RouteCondition) bool {
+	return anyLeaf(cond, func(leaf RouteCondition) bool {
+		dc, ok := leaf.(DispatchCondition)
+		return ok && dc.NeedsFileInfo()
+	})
+}
+
+// anyLeaf reports whether fn holds for any condition combined by And, Or,
+// Not and NamedCondition
+func anyLeaf(cond RouteCondition, fn func(RouteCondition) bool) bool {
+	switch c := cond.(type) {
+	case nil:
+		return false
+	case *andCondition:
+		for _, sub := range c.conditions {
+			if anyLeaf(sub, fn) {
+				return true
+			}
+		}
+		return false
+	case *orCondition:
+		for _, sub := range c.conditions {
+			if anyLeaf(sub, fn) {
+				return true
+			}
+		}
+		return false
+	case *notCondition:
+		return anyLeaf(c.condition, fn)
+	case *namedCondition:
+		return anyLeaf(c.condition, fn)
+	}
+	return fn(cond)
+}
+
+// holds reports whether a route's backend holds a path. Routes whose
+// conditions need file information serve the files they hold even once the
+// information no longer meets the condition, such as an archived file that
+// was touched, so that those files stay reachable.
+func holds(route *Route, path string) bool {
+	_, err := lstatBackend(route.backendFor(OpStat, path), path)
+	return err == nil
+}
+
+// Conditions on the clock, path names and backend status are evaluated
+// without file information; age, mode and ownership conditions read it.
+
+// NeedsFileInfo reports false, as schedules only read the clock
+func (c *ScheduleCondition) NeedsFileInfo() bool {
+	return false
+}
+
+// NeedsFileInfo reports false, as rollouts only hash the path
+func (c *RolloutCondition) NeedsFileInfo() bool {
+	return false
+}
+
+// NeedsFileInfo reports false, as backend conditions only read the status
+// backends report
+func (c *backendCondition) NeedsFileInfo() bool {
+	return false
+}
+
+// NeedsFileInfo reports false, as extensions are read from the path
+func (c *extensionCondition) NeedsFileInfo() bool {
+	return false
+}
+
+// NeedsFileInfo reports false, as globs only match the file name
+func (c *nameGlobCondition) NeedsFileInfo() bool {
+	return false
+}
+
+// NeedsFileInfo reports false, as patterns only match the path
+func (c *nameRegexCondition) NeedsFileInfo() bool {
+	return false
+}
+
+// NeedsFileInfo reports false, as depths are counted from the path
+func (c *depthCondition) NeedsFileInfo() bool {
+	return false
+}
+
+// NeedsFileInfo reports false, as hidden files are told by their name
+func (c *hiddenCondition) NeedsFileInfo() bool {
+	return false
+}
+
+// NeedsFileInfo reports true, as ages are read from file timestamps
+func (c *AgeCondition) NeedsFileInfo() bool {
+	return true
+}
+
+// NeedsFileInfo reports true, as modes are read from file information
+func (c *modeCondition) NeedsFileInfo() bool {
+	return true
+}
+
+// NeedsFileInfo reports true, as owners are read from file information
+func (c *ownerCondition) NeedsFileInfo() bool {
+	return true
+}
@@ -0,0 +1,188 @@
+package switchfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/absfs/memfs"
+)
+
+func TestDispatchConditions(t *testing.T) {
+	t.Run("markers", func(t *testing.T) {
+		glob, _ := NameGlob("*.log")
+		schedule, _ := Window("Mon-Fri")
+		tests := []struct {
+			cond      RouteCondition
+			needsInfo bool
+		}{
+			{schedule, false},
+			{Rollout(10), false},
+			{BackendHealthy(nil), false},
+			{HasExtension("png"), false},
+			{glob, false},
+			{Hidden(), false},
+			{ModifiedOlderThan(time.Hour), true},
+			{Executable(), true},
+			{OwnedBy(0), true},
+		}
+		for _, tt := range tests {
+			dc, ok := tt.cond.(DispatchCondition)
+			if !ok {
+				t.Errorf("%v is not a DispatchCondition", tt.cond)
+				continue
+			}
+			if dc.NeedsFileInfo() != tt.needsInfo {
+				t.Errorf("%v.NeedsFileInfo() = %v, want %v", tt.cond, dc.NeedsFileInfo(), tt.needsInfo)
+			}
+		}
+		if _, ok := MinSize(1).(DispatchCondition); ok {
+			t.Error("MinSize should only be evaluated with file information")
+		}
+	})
+
+	t.Run("name conditions", func(t *testing.T) {
+		images, _ := memfs.NewFS()
+		docs, _ := memfs.NewFS()
+		images.MkdirAll("/data", 0755)
+		docs.MkdirAll("/data", 0755)
+
+		fs, err := New(
+			WithRoute("/data", images, WithCondition(HasExtension("png")), WithPriority(10)),
+			WithRoute("/data", docs),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		writeTestFile(t, fs, "/data/photo.png", "image")
+		writeTestFile(t, fs, "/data/notes.txt", "text")
+		if _, err := images.Stat("/data/photo.png"); err != nil {
+			t.Errorf("image not on the image route: %v", err)
+		}
+		if _, err := docs.Stat("/data/notes.txt"); err != nil {
+			t.Errorf("text not on the default route: %v", err)
+		}
+	})
+
+	t.Run("name conditions on nested paths", func(t *testing.T) {
+		photos, _ := memfs.NewFS()
+		general, _ := memfs.NewFS()
+
+		fs, err := New(
+			WithRoute("/media", photos, WithCondition(HasExtension("jpg")), WithPriority(10)),
+			WithRoute("/media", general),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		if err := fs.MkdirAll("/media/2024", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, fs, "/media/2024/a.jpg", "image")
+		writeTestFile(t, fs, "/media/2024/b.txt", "text")
+		if _, err := photos.Stat("/media/2024/a.jpg"); err != nil {
+			t.Errorf("image not on the photo route: %v", err)
+		}
+
+		entries, err := fs.ReadDir("/media/2024")
+		if err != nil {
+			t.Fatalf("ReadDir() error = %v", err)
+		}
+		if len(entries) != 2 || entries[0].Name() != "a.jpg" || entries[1].Name() != "b.txt" {
+			t.Errorf("ReadDir() = %v, want a.jpg and b.txt", entries)
+		}
+	})
+
+	t.Run("age conditions", func(t *testing.T) {
+		hot, _ := memfs.NewFS()
+		cold, _ := memfs.NewFS()
+		hot.MkdirAll("/data", 0755)
+		cold.MkdirAll("/data", 0755)
+
+		now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+		writeTestFile(t, cold, "/data/archived.txt", "archived")
+		old := now.Add(-60 * 24 * time.Hour)
+		if err := cold.Chtimes("/data/archived.txt", old, old); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+
+		stale := ModifiedOlderThan(30 * 24 * time.Hour).WithClock(ClockFunc(func() time.Time { return now }))
+		fs, err := New(
+			WithRoute("/data", cold, WithCondition(stale), WithPriority(10)),
+			WithRoute("/data", hot),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		data, err := fs.ReadFile("/data/archived.txt")
+		if err != nil || string(data) != "archived" {
+			t.Errorf("ReadFile() = %q, %v, want the archived file", data, err)
+		}
+
+		// New files are not held by the cold backend, so they go to hot
+		writeTestFile(t, fs, "/data/new.txt", "new")
+		if _, err := hot.Stat("/data/new.txt"); err != nil {
+			t.Errorf("new file not on the hot route: %v", err)
+		}
+		if _, err := cold.Stat("/data/new.txt"); err == nil {
+			t.Error("new file placed on the cold route")
+		}
+
+		// Touching the archived file does not hide it
+		if err := fs.Chtimes("/data/archived.txt", now, now); err != nil {
+			t.Fatalf("Chtimes() error = %v", err)
+		}
+		if _, err := fs.Stat("/data/archived.txt"); err != nil {
+			t.Errorf("Stat() after Chtimes error = %v", err)
+		}
+		entries, err := fs.ReadDir("/data")
+		if err != nil {
+			t.Fatalf("ReadDir() error = %v", err)
+		}
+		if len(entries) != 2 {
+			t.Errorf("ReadDir() = %d entries, want the archived and new files", len(entries))
+		}
+		writeTestFile(t, fs, "/data/archived.txt", "updated")
+		if data, err := cold.ReadFile("/data/archived.txt"); err != nil || string(data) != "updated" {
+			t.Errorf("cold backend = %q, %v, want the file updated in place", data, err)
+		}
+		if _, err := hot.Stat("/data/archived.txt"); err == nil {
+			t.Error("touched file copied to the hot route")
+		}
+	})
+
+	t.Run("mode conditions", func(t *testing.T) {
+		hardened, _ := memfs.NewFS()
+		general, _ := memfs.NewFS()
+		hardened.MkdirAll("/bin", 0755)
+		general.MkdirAll("/bin", 0755)
+		writeTestFile(t, hardened, "/bin/tool", "binary")
+		if err := hardened.Chmod("/bin/tool", 0755); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+
+		fs, err := New(
+			WithRoute("/bin", hardened, WithCondition(Executable()), WithPriority(10)),
+			WithRoute("/bin", general),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		if _, err := fs.Stat("/bin/tool"); err != nil {
+			t.Errorf("Stat() error = %v, want the executable on the hardened route", err)
+		}
+		if err := fs.Chmod("/bin/tool", 0644); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+		if _, err := fs.Stat("/bin/tool"); err != nil {
+			t.Errorf("Stat() after Chmod error = %v", err)
+		}
+		writeTestFile(t, fs, "/bin/readme.txt", "docs")
+		if _, err := general.Stat("/bin/readme.txt"); err != nil {
+			t.Errorf("new file not on the general route: %v", err)
+		}
+	})
+}
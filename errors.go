@@ -20,4 +20,58 @@ var (
 
 	// ErrNilBackend is returned when a nil backend is provided
 	ErrNilBackend = errors.New("backend cannot be nil")
+
+	// ErrChecksumMismatch is returned when a transferred file does not match its source
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrSymlinksNotSupported is returned when a symlink operation routes to a backend without symlink support
+	ErrSymlinksNotSupported = errors.New("backend does not support symlinks")
+
+	// ErrSymlinkLoop is returned when resolving a path follows too many symbolic links
+	ErrSymlinkLoop = errors.New("too many levels of symbolic links")
+
+	// ErrSpecialFile is returned when a transfer encounters a device, named pipe or socket
+	ErrSpecialFile = errors.New("special file cannot be transferred")
+
+	// ErrQuorumNotMet is returned when a mirrored mutation fails on too many replicas
+	ErrQuorumNotMet = errors.New("write quorum not met")
+
+	// ErrInvalidQuorum is returned when a write quorum is outside the size of the mirror set
+	ErrInvalidQuorum = errors.New("invalid write quorum")
+
+	// ErrMirrorsUnsupported is returned when mirrors are set on a sharded or balanced route
+	ErrMirrorsUnsupported = errors.New("mirrors require a route with a single backend")
+
+	// ErrDuplicateShard is returned when two shards of a route share a name
+	ErrDuplicateShard = errors.New("shard with name already exists")
+
+	// ErrShardKeyRequired is returned when a sharded route with a glob or regex pattern has no shard key
+	ErrShardKeyRequired = errors.New("sharded route requires a shard key")
+
+	// ErrQuotaExceeded is returned when a write would exceed a route's byte or file quota
+	ErrQuotaExceeded = errors.New("route quota exceeded")
+
+	// ErrInvalidQuota is returned when a quota limit is negative
+	ErrInvalidQuota = errors.New("invalid quota")
+
+	// ErrRateLimited is returned when a non-blocking route is over its rate limit
+	ErrRateLimited = errors.New("rate limit exceeded")
+
+	// ErrInvalidRateLimit is returned when a rate limit is negative
+	ErrInvalidRateLimit = errors.New("invalid rate limit")
+
+	// ErrInvalidIDMap is returned when an ID map has negative IDs or maps two IDs to the same one
+	ErrInvalidIDMap = errors.New("invalid ID map")
+
+	// ErrInvalidSchedule is returned when a schedule expression cannot be parsed
+	ErrInvalidSchedule = errors.New("invalid schedule")
+
+	// ErrInvalidName is returned when registering a condition or rewriter with an empty name or a nil value
+	ErrInvalidName = errors.New("invalid registration name")
+
+	// ErrDuplicateName is returned when a condition or rewriter name is already registered
+	ErrDuplicateName = errors.New("name already registered")
+
+	// ErrUnknownName is returned when looking up a condition or rewriter name that is not registered
+	ErrUnknownName = errors.New("name not registered")
 )
@@ -42,7 +42,7 @@ func TestSwitchFS_Suite(t *testing.T) {
 	suite := &fstesting.Suite{
 		FS: fs,
 		Features: fstesting.Features{
-			Symlinks:      false,
+			Symlinks:      true,
 			HardLinks:     false,
 			Permissions:   true,
 			Timestamps:    true,
@@ -78,7 +78,7 @@ func TestSwitchFS_MultiBackend(t *testing.T) {
 	suite := &fstesting.Suite{
 		FS: fs,
 		Features: fstesting.Features{
-			Symlinks:      false,
+			Symlinks:      true,
 			HardLinks:     false,
 			Permissions:   true,
 			Timestamps:    true,
@@ -0,0 +1,176 @@
+package switchfs
+
+import (
+	"io/fs"
+	"os"
+	"reflect"
+)
+
+// idMapper translates user and group IDs between the SwitchFS namespace and
+// a route's backend. IDs without an entry pass through unchanged.
+type idMapper struct {
+	uids, gids         map[int]int
+	uidsBack, gidsBack map[int]int
+}
+
+// newIDMapper builds the ID mapper for a route with ID maps
+func newIDMapper(route *Route) (*idMapper, error) {
+	m := &idMapper{uids: route.UIDMap, gids: route.GIDMap}
+
+	var err error
+	if m.uidsBack, err = invertIDs(route.UIDMap); err != nil {
+		return nil, err
+	}
+	if m.gidsBack, err = invertIDs(route.GIDMap); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// invertIDs reverses an ID map, which must not map two IDs to the same one
+func invertIDs(ids map[int]int) (map[int]int, error) {
+	back := make(map[int]int, len(ids))
+	for from, to := range ids {
+		if from < 0 || to < 0 {
+			return nil, ErrInvalidIDMap
+		}
+		if _, ok := back[to]; ok {
+			return nil, ErrInvalidIDMap
+		}
+		back[to] = from
+	}
+	return back, nil
+}
+
+// mapID looks an ID up, leaving unmapped IDs and -1 unchanged
+func mapID(ids map[int]int, id int) int {
+	if mapped, ok := ids[id]; ok {
+		return mapped
+	}
+	return id
+}
+
+// toBackend maps the IDs of a Chown call toward the backend
+func (m *idMapper) toBackend(uid, gid int) (int, int) {
+	if m == nil {
+		return uid, gid
+	}
+	return mapID(m.uids, uid), mapID(m.gids, gid)
+}
+
+// fileInfo maps the ownership of backend file information back into the
+// SwitchFS namespace. Information whose Sys value carries no Uid and Gid
+// fields is returned unchanged.
+func (m *idMapper) fileInfo(info os.FileInfo) os.FileInfo {
+	if m == nil || info == nil {
+		return info
+	}
+	uid, gid, ok := ownership(info.Sys())
+	if !ok {
+		return info
+	}
+	mappedUID, mappedGID := mapID(m.uidsBack, uid), mapID(m.gidsBack, gid)
+	if mappedUID == uid && mappedGID == gid {
+		return info
+	}
+	return &mappedInfo{FileInfo: info, sys: withOwnership(info.Sys(), mappedUID, mappedGID)}
+}
+
+// dirEntries maps the ownership of directory entries back into the SwitchFS
+// namespace
+func (m *idMapper) dirEntries(entries []fs.DirEntry) []fs.DirEntry {
+	if m == nil {
+		return entries
+	}
+	mapped := make([]fs.DirEntry, len(entries))
+	for i, entry := range entries {
+		mapped[i] = &mappedEntry{DirEntry: entry, ids: m}
+	}
+	return mapped
+}
+
+// ownerFields returns the Uid and Gid fields of a Sys value that is a
+// pointer to a struct with integer fields of those names, such as
+// *syscall.Stat_t
+func ownerFields(sys any) (uid, gid reflect.Value, ok bool) {
+	v := reflect.ValueOf(sys)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return uid, gid, false
+	}
+	uid = v.Elem().FieldByName("Uid")
+	gid = v.Elem().FieldByName("Gid")
+	return uid, gid, isIntField(uid) && isIntField(gid)
+}
+
+// isIntField reports whether a field is an exported integer
+func isIntField(v reflect.Value) bool {
+	if !v.IsValid() || !v.CanInterface() {
+		return false
+	}
+	return v.CanInt() || v.CanUint()
+}
+
+// intOf reads an integer field
+func intOf(v reflect.Value) int {
+	if v.CanInt() {
+		return int(v.Int())
+	}
+	return int(v.Uint())
+}
+
+// setInt writes an integer field
+func setInt(v reflect.Value, n int) {
+	if v.CanInt() {
+		v.SetInt(int64(n))
+	} else {
+		v.SetUint(uint64(n))
+	}
+}
+
+// ownership reads the owner and group carried by a Sys value
+func ownership(sys any) (uid, gid int, ok bool) {
+	uidField, gidField, ok := ownerFields(sys)
+	if !ok {
+		return 0, 0, false
+	}
+	return intOf(uidField), intOf(gidField), true
+}
+
+// withOwnership returns a copy of a Sys value carrying a different owner and
+// group, leaving the backend's value untouched
+func withOwnership(sys any, uid, gid int) any {
+	v := reflect.ValueOf(sys)
+	cp := reflect.New(v.Elem().Type())
+	cp.Elem().Set(v.Elem())
+
+	uidField, gidField, _ := ownerFields(cp.Interface())
+	setInt(uidField, uid)
+	setInt(gidField, gid)
+	return cp.Interface()
+}
+
+// mappedInfo is file information with ownership mapped into the SwitchFS
+// namespace
+type mappedInfo struct {
+	os.FileInfo
+	sys any
+}
+
+func (i *mappedInfo) Sys() any {
+	return i.sys
+}
+
+// mappedEntry is a directory entry whose information has ownership mapped
+// into the SwitchFS namespace
+type mappedEntry struct {
+	fs.DirEntry
+	ids *idMapper
+}
+
+func (e *mappedEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return e.ids.fileInfo(info), nil
+}
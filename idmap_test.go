@@ -0,0 +1,79 @@
+package switchfs
+
+import (
+	"testing"
+
+	"github.com/absfs/memfs"
+)
+
+func TestIDMap(t *testing.T) {
+	mem, _ := memfs.NewFS()
+	mem.MkdirAll("/remote", 0755)
+	writeTestFile(t, mem, "/remote/file.txt", "owned")
+
+	fs, err := New(WithRoute("/remote", mem, WithIDMap(map[int]int{1000: 5000}, map[int]int{100: 600})))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// wantOwner checks the ownership carried by file information
+	wantOwner := func(t *testing.T, what string, sys any, uid, gid int) {
+		t.Helper()
+		gotUID, gotGID, ok := ownership(sys)
+		if !ok || gotUID != uid || gotGID != gid {
+			t.Errorf("%s owner = %d:%d (%v), want %d:%d", what, gotUID, gotGID, ok, uid, gid)
+		}
+	}
+
+	if err := fs.Chown("/remote/file.txt", 1000, 100); err != nil {
+		t.Fatalf("Chown() error = %v", err)
+	}
+
+	backendInfo, err := mem.Stat("/remote/file.txt")
+	if err != nil {
+		t.Fatalf("backend Stat() error = %v", err)
+	}
+	wantOwner(t, "backend", backendInfo.Sys(), 5000, 600)
+
+	info, err := fs.Stat("/remote/file.txt")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	wantOwner(t, "Stat()", info.Sys(), 1000, 100)
+	if info.Name() != "file.txt" || info.Size() != 5 {
+		t.Errorf("Stat() = %s, %d bytes", info.Name(), info.Size())
+	}
+
+	info, err = fs.Lstat("/remote/file.txt")
+	if err != nil {
+		t.Fatalf("Lstat() error = %v", err)
+	}
+	wantOwner(t, "Lstat()", info.Sys(), 1000, 100)
+
+	entries, err := fs.ReadDir("/remote")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ReadDir() = %v, %v", entries, err)
+	}
+	info, err = entries[0].Info()
+	if err != nil {
+		t.Fatalf("Info() error = %v", err)
+	}
+	wantOwner(t, "ReadDir()", info.Sys(), 1000, 100)
+
+	// The backend's own information is not modified
+	wantOwner(t, "backend after Stat()", backendInfo.Sys(), 5000, 600)
+
+	// Unmapped IDs pass through
+	if err := fs.Chown("/remote/file.txt", 42, 7); err != nil {
+		t.Fatalf("Chown() error = %v", err)
+	}
+	info, _ = fs.Stat("/remote/file.txt")
+	wantOwner(t, "unmapped Stat()", info.Sys(), 42, 7)
+
+	t.Run("invalid map", func(t *testing.T) {
+		_, err := New(WithRoute("/x", mem, WithIDMap(map[int]int{1: 7, 2: 7}, nil)))
+		if err != ErrInvalidIDMap {
+			t.Errorf("New() error = %v, want ErrInvalidIDMap", err)
+		}
+	})
+}
@@ -0,0 +1,424 @@
+package switchfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/absfs/absfs"
+)
+
+// Divergence describes a replica that failed an operation which the rest of
+// its mirror set completed
+type Divergence struct {
+	// Op is the operation that failed
+	Op Operation
+
+	// Path is the path the operation was applied to
+	Path string
+
+	// Replica is the index of the replica in the mirror set; the route's
+	// backend is replica 0 and its mirrors follow in order
+	Replica int
+
+	// Backend is the replica that failed
+	Backend absfs.FileSystem
+
+	// Err is the error returned by the replica
+	Err error
+}
+
+// MirrorError is returned when a mirrored operation fails on too many
+// replicas to meet the write quorum
+type MirrorError struct {
+	Op       Operation
+	Path     string
+	Failures []Divergence
+}
+
+func (e *MirrorError) Error() string {
+	return fmt.Sprintf("%s %s: %v (%d replicas failed)", e.Op, e.Path, ErrQuorumNotMet, len(e.Failures))
+}
+
+// Unwrap returns ErrQuorumNotMet
+func (e *MirrorError) Unwrap() error {
+	return ErrQuorumNotMet
+}
+
+// mirrorFS fans mutations out to every replica of a route and serves reads
+// from the first replica that succeeds
+type mirrorFS struct {
+	replicas     []absfs.FileSystem
+	quorum       int
+	onDivergence func(Divergence)
+}
+
+// Ensure mirrorFS implements absfs.SymlinkFileSystem
+var _ absfs.SymlinkFileSystem = (*mirrorFS)(nil)
+
+// newMirrorFS builds the mirror set for a route with mirrors. Sharded and
+// balanced routes spread paths over their own backends and cannot be
+// mirrored.
+func newMirrorFS(route *Route) (*mirrorFS, error) {
+	if route.Backend == nil || len(route.Shards) > 0 || len(route.Targets) > 0 {
+		return nil, ErrMirrorsUnsupported
+	}
+	replicas := append([]absfs.FileSystem{route.Backend}, route.Mirrors...)
+
+	quorum := route.WriteQuorum
+	if quorum == 0 {
+		quorum = len(replicas)
+	}
+	if quorum < 1 || quorum > len(replicas) {
+		return nil, ErrInvalidQuorum
+	}
+
+	return &mirrorFS{replicas: replicas, quorum: quorum, onDivergence: route.OnDivergence}, nil
+}
+
+// fanOut applies fn to every replica, reporting replicas that fail while
+// the quorum succeeds, and failing when the quorum is not met
+func (m *mirrorFS) fanOut(op Operation, name string, fn func(absfs.FileSystem) error) error {
+	var failures []Divergence
+	for i, replica := range m.replicas {
+		if err := fn(replica); err != nil {
+			failures = append(failures, Divergence{Op: op, Path: name, Replica: i, Backend: replica, Err: err})
+		}
+	}
+	return m.settle(op, name, len(m.replicas)-len(failures), failures)
+}
+
+// settle checks the number of successful replicas against the quorum
+func (m *mirrorFS) settle(op Operation, name string, succeeded int, failures []Divergence) error {
+	if succeeded < m.quorum {
+		return &MirrorError{Op: op, Path: name, Failures: failures}
+	}
+	if m.onDivergence != nil {
+		for _, d := range failures {
+			m.onDivergence(d)
+		}
+	}
+	return nil
+}
+
+// firstHealthy returns the result of fn on the first replica where it succeeds,
+// or the first replica's error if it fails everywhere
+func firstHealthy[T any](m *mirrorFS, fn func(absfs.FileSystem) (T, error)) (T, error) {
+	var firstErr error
+	for _, replica := range m.replicas {
+		v, err := fn(replica)
+		if err == nil {
+			return v, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	var zero T
+	return zero, firstErr
+}
+
+// OpenFile opens name on the first healthy replica for reading, or on every
+// replica for writing
+func (m *mirrorFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	op := openOperation(flag)
+	if !op.IsWrite() {
+		return firstHealthy(m, func(b absfs.FileSystem) (absfs.File, error) {
+			return b.OpenFile(name, flag, perm)
+		})
+	}
+
+	f := &mirrorFile{fs: m, name: name, append: flag&os.O_APPEND != 0}
+	var failures []Divergence
+	for i, replica := range m.replicas {
+		rf, err := replica.OpenFile(name, flag, perm)
+		if err != nil {
+			failures = append(failures, Divergence{Op: op, Path: name, Replica: i, Backend: replica, Err: err})
+			continue
+		}
+		f.files = append(f.files, mirrorReplicaFile{index: i, backend: replica, file: rf})
+	}
+	if err := m.settle(op, name, len(f.files), failures); err != nil {
+		f.closeAll()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (m *mirrorFS) Open(name string) (absfs.File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (m *mirrorFS) Create(name string) (absfs.File, error) {
+	return m.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (m *mirrorFS) Mkdir(name string, perm os.FileMode) error {
+	return m.fanOut(OpMkdir, name, func(b absfs.FileSystem) error { return b.Mkdir(name, perm) })
+}
+
+func (m *mirrorFS) MkdirAll(name string, perm os.FileMode) error {
+	return m.fanOut(OpMkdir, name, func(b absfs.FileSystem) error { return b.MkdirAll(name, perm) })
+}
+
+func (m *mirrorFS) Remove(name string) error {
+	return m.fanOut(OpRemove, name, func(b absfs.FileSystem) error { return b.Remove(name) })
+}
+
+func (m *mirrorFS) RemoveAll(name string) error {
+	return m.fanOut(OpRemove, name, func(b absfs.FileSystem) error { return b.RemoveAll(name) })
+}
+
+func (m *mirrorFS) Rename(oldpath, newpath string) error {
+	return m.fanOut(OpRename, oldpath, func(b absfs.FileSystem) error { return b.Rename(oldpath, newpath) })
+}
+
+func (m *mirrorFS) Chmod(name string, mode os.FileMode) error {
+	return m.fanOut(OpChmod, name, func(b absfs.FileSystem) error { return b.Chmod(name, mode) })
+}
+
+func (m *mirrorFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return m.fanOut(OpChtimes, name, func(b absfs.FileSystem) error { return b.Chtimes(name, atime, mtime) })
+}
+
+func (m *mirrorFS) Chown(name string, uid, gid int) error {
+	return m.fanOut(OpChown, name, func(b absfs.FileSystem) error { return b.Chown(name, uid, gid) })
+}
+
+func (m *mirrorFS) Truncate(name string, size int64) error {
+	return m.fanOut(OpTruncate, name, func(b absfs.FileSystem) error { return b.Truncate(name, size) })
+}
+
+func (m *mirrorFS) Stat(name string) (os.FileInfo, error) {
+	return firstHealthy(m, func(b absfs.FileSystem) (os.FileInfo, error) { return b.Stat(name) })
+}
+
+func (m *mirrorFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return firstHealthy(m, func(b absfs.FileSystem) ([]fs.DirEntry, error) { return b.ReadDir(name) })
+}
+
+func (m *mirrorFS) ReadFile(name string) ([]byte, error) {
+	return firstHealthy(m, func(b absfs.FileSystem) ([]byte, error) { return b.ReadFile(name) })
+}
+
+func (m *mirrorFS) Sub(dir string) (fs.FS, error) {
+	return firstHealthy(m, func(b absfs.FileSystem) (fs.FS, error) { return b.Sub(dir) })
+}
+
+func (m *mirrorFS) Chdir(dir string) error {
+	return m.replicas[0].Chdir(dir)
+}
+
+func (m *mirrorFS) Getwd() (string, error) {
+	return m.replicas[0].Getwd()
+}
+
+func (m *mirrorFS) TempDir() string {
+	return m.replicas[0].TempDir()
+}
+
+func (m *mirrorFS) Lstat(name string) (os.FileInfo, error) {
+	return firstHealthy(m, func(b absfs.FileSystem) (os.FileInfo, error) { return lstatBackend(b, name) })
+}
+
+func (m *mirrorFS) Readlink(name string) (string, error) {
+	return firstHealthy(m, func(b absfs.FileSystem) (string, error) {
+		linker, ok := b.(absfs.SymLinker)
+		if !ok {
+			return "", &os.PathError{Op: "readlink", Path: name, Err: ErrSymlinksNotSupported}
+		}
+		return linker.Readlink(name)
+	})
+}
+
+func (m *mirrorFS) Symlink(oldname, newname string) error {
+	return m.fanOut(OpSymlink, newname, func(b absfs.FileSystem) error {
+		linker, ok := b.(absfs.SymLinker)
+		if !ok {
+			return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrSymlinksNotSupported}
+		}
+		return linker.Symlink(oldname, newname)
+	})
+}
+
+func (m *mirrorFS) Lchown(name string, uid, gid int) error {
+	return m.fanOut(OpChown, name, func(b absfs.FileSystem) error {
+		if linker, ok := b.(absfs.SymLinker); ok {
+			return linker.Lchown(name, uid, gid)
+		}
+		return b.Chown(name, uid, gid)
+	})
+}
+
+// mirrorReplicaFile is a file opened on one replica of a mirror set
+type mirrorReplicaFile struct {
+	index   int
+	backend absfs.FileSystem
+	file    absfs.File
+}
+
+// mirrorFile writes to a file open on several replicas. Replicas that fail a
+// write are dropped and reported; reads are served by the first remaining
+// replica. The file keeps one offset for all replicas and reads and writes
+// at it, so that replicas receive writes at the same positions however
+// reads and writes are interleaved.
+type mirrorFile struct {
+	fs     *mirrorFS
+	name   string
+	files  []mirrorReplicaFile
+	offset int64
+	append bool
+	closed bool
+}
+
+// each applies fn to every open replica, dropping those that fail
+func (f *mirrorFile) each(fn func(absfs.File) error) error {
+	var failures []Divergence
+	kept := f.files[:0]
+	for _, rf := range f.files {
+		if err := fn(rf.file); err != nil {
+			failures = append(failures, Divergence{Op: OpWrite, Path: f.name, Replica: rf.index, Backend: rf.backend, Err: err})
+			rf.file.Close()
+			continue
+		}
+		kept = append(kept, rf)
+	}
+	f.files = kept
+	return f.fs.settle(OpWrite, f.name, len(f.files), failures)
+}
+
+// first returns the replica file serving reads
+func (f *mirrorFile) first() absfs.File {
+	if len(f.files) == 0 {
+		return &absfs.InvalidFile{Path: f.name}
+	}
+	return f.files[0].file
+}
+
+// closeAll closes every open replica without reporting errors
+func (f *mirrorFile) closeAll() {
+	for _, rf := range f.files {
+		rf.file.Close()
+	}
+}
+
+func (f *mirrorFile) Name() string {
+	return f.name
+}
+
+func (f *mirrorFile) Read(p []byte) (int, error) {
+	n, err := f.first().ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (f *mirrorFile) ReadAt(p []byte, off int64) (int, error) {
+	return f.first().ReadAt(p, off)
+}
+
+func (f *mirrorFile) Write(p []byte) (int, error) {
+	// Appends land at each replica's own end of file
+	if f.append {
+		err := f.each(func(file absfs.File) error {
+			n, err := file.Write(p)
+			if err == nil && n != len(p) {
+				err = io.ErrShortWrite
+			}
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+		f.offset, err = f.first().Seek(0, io.SeekCurrent)
+		return len(p), err
+	}
+
+	n, err := f.WriteAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *mirrorFile) WriteAt(p []byte, off int64) (int, error) {
+	err := f.each(func(file absfs.File) error {
+		n, err := file.WriteAt(p, off)
+		if err == nil && n != len(p) {
+			err = io.ErrShortWrite
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (f *mirrorFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *mirrorFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		info, err := f.first().Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += info.Size()
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrInvalid}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *mirrorFile) Truncate(size int64) error {
+	return f.each(func(file absfs.File) error { return file.Truncate(size) })
+}
+
+func (f *mirrorFile) Sync() error {
+	return f.each(func(file absfs.File) error { return file.Sync() })
+}
+
+func (f *mirrorFile) Close() error {
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	f.closed = true
+
+	var failures []Divergence
+	for _, rf := range f.files {
+		if err := rf.file.Close(); err != nil {
+			failures = append(failures, Divergence{Op: OpWrite, Path: f.name, Replica: rf.index, Backend: rf.backend, Err: err})
+		}
+	}
+	succeeded := len(f.files) - len(failures)
+	f.files = nil
+	return f.fs.settle(OpWrite, f.name, succeeded, failures)
+}
+
+func (f *mirrorFile) Stat() (os.FileInfo, error) {
+	return f.first().Stat()
+}
+
+func (f *mirrorFile) Readdir(n int) ([]os.FileInfo, error) {
+	return f.first().Readdir(n)
+}
+
+func (f *mirrorFile) Readdirnames(n int) ([]string, error) {
+	return f.first().Readdirnames(n)
+}
+
+func (f *mirrorFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	return f.first().ReadDir(n)
+}
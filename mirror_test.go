@@ -0,0 +1,235 @@
+package switchfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/memfs"
+)
+
+// brokenFS fails every call it overrides, standing in for an unhealthy replica
+type brokenFS struct {
+	absfs.FileSystem
+}
+
+var errBroken = errors.New("replica unavailable")
+
+func (b *brokenFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
+	return nil, errBroken
+}
+
+func (b *brokenFS) Mkdir(name string, perm os.FileMode) error {
+	return errBroken
+}
+
+func (b *brokenFS) MkdirAll(name string, perm os.FileMode) error {
+	return errBroken
+}
+
+func (b *brokenFS) Stat(name string) (os.FileInfo, error) {
+	return nil, errBroken
+}
+
+func (b *brokenFS) ReadFile(name string) ([]byte, error) {
+	return nil, errBroken
+}
+
+// newReplicas creates n memfs backends with a /data directory
+func newReplicas(t *testing.T, n int) []absfs.FileSystem {
+	t.Helper()
+
+	replicas := make([]absfs.FileSystem, n)
+	for i := range replicas {
+		mem, err := memfs.NewFS()
+		if err != nil {
+			t.Fatalf("NewFS() error = %v", err)
+		}
+		mem.MkdirAll("/data", 0755)
+		replicas[i] = mem
+	}
+	return replicas
+}
+
+func TestMirrors(t *testing.T) {
+	t.Run("mutations reach every replica", func(t *testing.T) {
+		r := newReplicas(t, 3)
+		fs, err := New(WithRoute("/data", r[0], WithMirrors(r[1], r[2])))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		f, err := fs.Create("/data/file.txt")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, err := f.Write([]byte("mirrored")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if err := fs.Mkdir("/data/dir", 0755); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		if err := fs.Chmod("/data/file.txt", 0600); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+		if err := fs.Rename("/data/file.txt", "/data/dir/moved.txt"); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+
+		for i, replica := range r {
+			data, err := replica.ReadFile("/data/dir/moved.txt")
+			if err != nil || string(data) != "mirrored" {
+				t.Errorf("replica %d: ReadFile() = %q, %v", i, data, err)
+			}
+			info, err := replica.Stat("/data/dir/moved.txt")
+			if err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("replica %d: mode = %v, %v", i, info.Mode(), err)
+			}
+		}
+	})
+
+	t.Run("quorum met reports divergence", func(t *testing.T) {
+		r := newReplicas(t, 3)
+		broken := &brokenFS{FileSystem: r[2]}
+
+		var diverged []Divergence
+		fs, err := New(WithRoute("/data", r[0],
+			WithMirrors(r[1], broken),
+			WithWriteQuorum(2),
+			WithDivergenceHandler(func(d Divergence) { diverged = append(diverged, d) }),
+		))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		if err := fs.Mkdir("/data/dir", 0755); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		f, err := fs.Create("/data/file.txt")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		f.Write([]byte("x"))
+		f.Close()
+
+		if len(diverged) != 2 {
+			t.Fatalf("divergences = %d, want 2", len(diverged))
+		}
+		for _, d := range diverged {
+			if d.Replica != 2 || d.Backend != broken || !errors.Is(d.Err, errBroken) {
+				t.Errorf("divergence = %+v, want replica 2", d)
+			}
+		}
+		if diverged[0].Op != OpMkdir || diverged[1].Op != OpCreate {
+			t.Errorf("divergence ops = %v, %v", diverged[0].Op, diverged[1].Op)
+		}
+	})
+
+	t.Run("quorum not met", func(t *testing.T) {
+		r := newReplicas(t, 2)
+		fs, err := New(WithRoute("/data", r[0], WithMirrors(&brokenFS{FileSystem: r[1]})))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		err = fs.Mkdir("/data/dir", 0755)
+		if !errors.Is(err, ErrQuorumNotMet) {
+			t.Fatalf("Mkdir() error = %v, want ErrQuorumNotMet", err)
+		}
+		var mirrorErr *MirrorError
+		if !errors.As(err, &mirrorErr) || len(mirrorErr.Failures) != 1 || mirrorErr.Failures[0].Replica != 1 {
+			t.Errorf("error = %#v, want MirrorError naming replica 1", err)
+		}
+
+		if _, err := fs.Create("/data/file.txt"); !errors.Is(err, ErrQuorumNotMet) {
+			t.Errorf("Create() error = %v, want ErrQuorumNotMet", err)
+		}
+	})
+
+	t.Run("reads from first healthy replica", func(t *testing.T) {
+		r := newReplicas(t, 2)
+		writeTestFile(t, r[1], "/data/file.txt", "healthy")
+
+		fs, err := New(WithRoute("/data", &brokenFS{FileSystem: r[0]}, WithMirrors(r[1])))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		data, err := fs.ReadFile("/data/file.txt")
+		if err != nil || string(data) != "healthy" {
+			t.Errorf("ReadFile() = %q, %v", data, err)
+		}
+		if _, err := fs.Stat("/data/file.txt"); err != nil {
+			t.Errorf("Stat() error = %v", err)
+		}
+	})
+
+	t.Run("reads and writes share one offset", func(t *testing.T) {
+		r := newReplicas(t, 2)
+		writeTestFile(t, r[0], "/data/file.txt", "hello world")
+		writeTestFile(t, r[1], "/data/file.txt", "hello world")
+
+		fs, err := New(WithRoute("/data", r[0], WithMirrors(r[1])))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		f, err := fs.OpenFile("/data/file.txt", os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		buf := make([]byte, 6)
+		if _, err := io.ReadFull(f, buf); err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		if _, err := f.Write([]byte("WORLD")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		if pos, err := f.Seek(-5, io.SeekEnd); err != nil || pos != 6 {
+			t.Errorf("Seek() = %d, %v, want 6", pos, err)
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+		if err := f.Close(); errors.Is(err, ErrQuorumNotMet) || err == nil {
+			t.Errorf("second Close() error = %v, want a closed-file error", err)
+		}
+
+		for i, replica := range r {
+			data, _ := replica.ReadFile("/data/file.txt")
+			if string(data) != "hello WORLD" {
+				t.Errorf("replica %d = %q, want %q", i, data, "hello WORLD")
+			}
+		}
+	})
+
+	t.Run("sharded and balanced routes reject mirrors", func(t *testing.T) {
+		r := newReplicas(t, 3)
+		_, err := New(WithShardedRoute("/data", []Shard{{Name: "a", Backend: r[0]}}, WithMirrors(r[2])))
+		if err != ErrMirrorsUnsupported {
+			t.Errorf("sharded New() error = %v, want ErrMirrorsUnsupported", err)
+		}
+		_, err = New(WithBalancedRoute("/data", BalanceRoundRobin, []Target{{Backend: r[0]}, {Backend: r[1]}}, WithMirrors(r[2])))
+		if err != ErrMirrorsUnsupported {
+			t.Errorf("balanced New() error = %v, want ErrMirrorsUnsupported", err)
+		}
+	})
+
+	t.Run("invalid quorum", func(t *testing.T) {
+		r := newReplicas(t, 2)
+		_, err := New(WithRoute("/data", r[0], WithMirrors(r[1]), WithWriteQuorum(3)))
+		if err != ErrInvalidQuorum {
+			t.Errorf("New() error = %v, want ErrInvalidQuorum", err)
+		}
+		if err := WithWriteQuorum(0)(&Route{}); err != ErrInvalidQuorum {
+			t.Errorf("WithWriteQuorum(0) error = %v, want ErrInvalidQuorum", err)
+		}
+		if err := WithMirrors(nil)(&Route{}); err != ErrNilBackend {
+			t.Errorf("WithMirrors(nil) error = %v, want ErrNilBackend", err)
+		}
+	})
+}
@@ -0,0 +1,82 @@
+package switchfs
+
+import (
+	"os"
+	"path"
+
+	"github.com/absfs/absfs"
+)
+
+// hasModePolicy reports whether a route controls the permissions of the
+// files and directories created under it
+func (r *Route) hasModePolicy() bool {
+	return r != nil && (r.Umask != 0 || r.FileMode != 0 || r.DirMode != 0)
+}
+
+// createMode returns the permissions a new file or directory on the route is
+// created with: the route's default mode if set, else the caller's, less the
+// route's umask
+func (r *Route) createMode(perm os.FileMode, dir bool) os.FileMode {
+	if r == nil {
+		return perm
+	}
+	def := r.FileMode
+	if dir {
+		def = r.DirMode
+	}
+	if def != 0 {
+		perm = perm&^os.ModePerm | def&os.ModePerm
+	}
+	return perm &^ (r.Umask & os.ModePerm)
+}
+
+// chmodMode returns the mode a Chmod call on the route applies. When the
+// route clamps Chmod, permission bits outside the route's default mode for
+// the file's type, and bits in its umask, are dropped.
+func (r *Route) chmodMode(mode os.FileMode, dir bool) os.FileMode {
+	if r == nil || !r.ClampChmod {
+		return mode
+	}
+	allowed := os.ModePerm
+	if def := r.createMode(0, dir); def != 0 {
+		allowed = def
+	} else {
+		allowed &^= r.Umask & os.ModePerm
+	}
+	return mode&^os.ModePerm | mode&allowed
+}
+
+// missingDirs lists name and those of its ancestors that do not exist on a
+// backend, deepest first
+func missingDirs(backend absfs.FileSystem, name string) []string {
+	var missing []string
+	for dir := path.Clean(name); ; dir = path.Dir(dir) {
+		if _, err := backend.Stat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		if dir == "/" || dir == "." {
+			break
+		}
+	}
+	return missing
+}
+
+// enforceMode sets the exact permissions of newly created paths, so that
+// neither the backend nor a process umask changes the route's mode. Type
+// bits are kept for backends whose Chmod replaces the whole mode.
+func enforceMode(backend absfs.FileSystem, perm os.FileMode, names ...string) error {
+	for _, name := range names {
+		info, err := backend.Stat(name)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModePerm == perm&os.ModePerm {
+			continue
+		}
+		if err := backend.Chmod(name, info.Mode().Type()|perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package switchfs
+
+import (
+	"os"
+	"testing"
+
+	"github.com/absfs/memfs"
+)
+
+func TestModePolicy(t *testing.T) {
+	shared, _ := memfs.NewFS()
+	private, _ := memfs.NewFS()
+	shared.MkdirAll("/shared", 0755)
+	private.MkdirAll("/private", 0755)
+
+	fs, err := New(
+		WithRoute("/shared", shared, WithDefaultModes(0664, 0775), WithChmodClamp()),
+		WithRoute("/private", private, WithUmask(0077)),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// wantMode checks the permissions of a path
+	wantMode := func(t *testing.T, name string, want os.FileMode, dir bool) {
+		t.Helper()
+		info, err := fs.Stat(name)
+		if err != nil {
+			t.Fatalf("Stat(%s) error = %v", name, err)
+		}
+		if info.Mode().Perm() != want || info.IsDir() != dir {
+			t.Errorf("%s mode = %v, want %v (dir %v)", name, info.Mode(), want, dir)
+		}
+	}
+
+	t.Run("default modes replace caller's", func(t *testing.T) {
+		f, err := fs.OpenFile("/shared/a.txt", os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		f.Close()
+		wantMode(t, "/shared/a.txt", 0664, false)
+
+		if err := fs.Mkdir("/shared/dir", 0700); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		wantMode(t, "/shared/dir", 0775, true)
+
+		if err := fs.MkdirAll("/shared/x/y/z", 0700); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		for _, dir := range []string{"/shared/x", "/shared/x/y", "/shared/x/y/z"} {
+			wantMode(t, dir, 0775, true)
+		}
+	})
+
+	t.Run("existing file keeps its mode", func(t *testing.T) {
+		shared.Chmod("/shared/a.txt", 0644)
+		f, err := fs.OpenFile("/shared/a.txt", os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatalf("OpenFile() error = %v", err)
+		}
+		f.Close()
+		wantMode(t, "/shared/a.txt", 0644, false)
+	})
+
+	t.Run("chmod clamped", func(t *testing.T) {
+		if err := fs.Chmod("/shared/a.txt", 0777); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+		wantMode(t, "/shared/a.txt", 0664, false)
+		if err := fs.Chmod("/shared/dir", 0777); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+		wantMode(t, "/shared/dir", 0775, true)
+		if err := fs.Chmod("/shared/a.txt", 0600); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+		wantMode(t, "/shared/a.txt", 0600, false)
+	})
+
+	t.Run("umask", func(t *testing.T) {
+		f, err := fs.Create("/private/a.txt")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		f.Close()
+		wantMode(t, "/private/a.txt", 0600, false)
+
+		if err := fs.Mkdir("/private/dir", 0755); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		wantMode(t, "/private/dir", 0700, true)
+
+		// Chmod is not clamped unless requested
+		if err := fs.Chmod("/private/a.txt", 0644); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+		wantMode(t, "/private/a.txt", 0644, false)
+	})
+}
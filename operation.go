@@ -0,0 +1,107 @@
+package switchfs
+
+import (
+	"os"
+	"strings"
+)
+
+// Operation identifies the kind of filesystem call being routed. Operations
+// are bit flags so that sets of them can be combined with |.
+type Operation uint32
+
+const (
+	// OpOpen opens a file or directory for reading
+	OpOpen Operation = 1 << iota
+	// OpCreate creates a file, via Create or OpenFile with O_CREATE
+	OpCreate
+	// OpWrite opens an existing file with write flags
+	OpWrite
+	// OpMkdir creates directories, via Mkdir or MkdirAll
+	OpMkdir
+	// OpRemove removes files or directories, via Remove or RemoveAll
+	OpRemove
+	// OpRename renames or moves a path
+	OpRename
+	// OpStat reads file information, via Stat or Lstat
+	OpStat
+	// OpReadDir lists a directory
+	OpReadDir
+	// OpReadFile reads a whole file
+	OpReadFile
+	// OpChmod changes permissions
+	OpChmod
+	// OpChtimes changes access and modification times
+	OpChtimes
+	// OpChown changes ownership, via Chown or Lchown
+	OpChown
+	// OpTruncate changes the size of a file
+	OpTruncate
+	// OpSymlink creates a symbolic link
+	OpSymlink
+	// OpReadlink reads the target of a symbolic link
+	OpReadlink
+)
+
+const (
+	// OpRead is the set of operations that only read
+	OpRead = OpOpen | OpStat | OpReadDir | OpReadFile | OpReadlink
+
+	// OpMutate is the set of operations that modify the filesystem
+	OpMutate = OpCreate | OpWrite | OpMkdir | OpRemove | OpRename | OpChmod | OpChtimes | OpChown | OpTruncate | OpSymlink
+
+	// OpAll is the set of all operations
+	OpAll = OpRead | OpMutate
+)
+
+// operationNames maps each single operation to its name
+var operationNames = []struct {
+	op   Operation
+	name string
+}{
+	{OpOpen, "open"},
+	{OpCreate, "create"},
+	{OpWrite, "write"},
+	{OpMkdir, "mkdir"},
+	{OpRemove, "remove"},
+	{OpRename, "rename"},
+	{OpStat, "stat"},
+	{OpReadDir, "readdir"},
+	{OpReadFile, "readfile"},
+	{OpChmod, "chmod"},
+	{OpChtimes, "chtimes"},
+	{OpChown, "chown"},
+	{OpTruncate, "truncate"},
+	{OpSymlink, "symlink"},
+	{OpReadlink, "readlink"},
+}
+
+// String returns the string representation of Operation. Sets of operations
+// are joined with "|".
+func (op Operation) String() string {
+	var names []string
+	for _, n := range operationNames {
+		if op&n.op != 0 {
+			names = append(names, n.name)
+		}
+	}
+	if len(names) == 0 {
+		return "unknown"
+	}
+	return strings.Join(names, "|")
+}
+
+// IsWrite reports whether the operation modifies the filesystem
+func (op Operation) IsWrite() bool {
+	return op&OpMutate != 0
+}
+
+// openOperation classifies an OpenFile call by its flags
+func openOperation(flag int) Operation {
+	if flag&os.O_CREATE != 0 {
+		return OpCreate
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_TRUNC) != 0 {
+		return OpWrite
+	}
+	return OpOpen
+}
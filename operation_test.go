@@ -0,0 +1,303 @@
+package switchfs
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/memfs"
+)
+
+func TestOperationString(t *testing.T) {
+	tests := []struct {
+		op   Operation
+		want string
+	}{
+		{OpOpen, "open"},
+		{OpCreate, "create"},
+		{OpReadlink, "readlink"},
+		{OpCreate | OpMkdir, "create|mkdir"},
+		{Operation(0), "unknown"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.op.String(); got != tt.want {
+			t.Errorf("Operation(%d).String() = %q, want %q", tt.op, got, tt.want)
+		}
+	}
+}
+
+func TestOpenOperation(t *testing.T) {
+	tests := []struct {
+		name string
+		flag int
+		want Operation
+	}{
+		{"read only", os.O_RDONLY, OpOpen},
+		{"write only", os.O_WRONLY, OpWrite},
+		{"read write", os.O_RDWR, OpWrite},
+		{"append", os.O_WRONLY | os.O_APPEND, OpWrite},
+		{"create", os.O_RDWR | os.O_CREATE | os.O_TRUNC, OpCreate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := openOperation(tt.flag)
+			if got != tt.want {
+				t.Errorf("openOperation() = %v, want %v", got, tt.want)
+			}
+			if got.IsWrite() != (tt.want != OpOpen) {
+				t.Errorf("IsWrite() = %v", got.IsWrite())
+			}
+		})
+	}
+}
+
+func TestReadBackend(t *testing.T) {
+	primary, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+	replica, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+
+	fs, err := New(WithRoute("/data", primary, WithReadBackend(replica)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	primary.MkdirAll("/data", 0755)
+	replica.MkdirAll("/data", 0755)
+	writeTestFile(t, replica, "/data/cached.txt", "from replica")
+
+	t.Run("writes go to primary", func(t *testing.T) {
+		f, err := fs.Create("/data/new.txt")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		f.Write([]byte("written"))
+		f.Close()
+
+		if _, err := primary.Stat("/data/new.txt"); err != nil {
+			t.Errorf("file not created on primary: %v", err)
+		}
+		if _, err := replica.Stat("/data/new.txt"); err == nil {
+			t.Error("file created on replica")
+		}
+
+		if err := fs.Mkdir("/data/dir", 0755); err != nil {
+			t.Fatalf("Mkdir() error = %v", err)
+		}
+		if _, err := primary.Stat("/data/dir"); err != nil {
+			t.Errorf("directory not created on primary: %v", err)
+		}
+	})
+
+	t.Run("reads go to replica", func(t *testing.T) {
+		data, err := fs.ReadFile("/data/cached.txt")
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if string(data) != "from replica" {
+			t.Errorf("ReadFile() = %q", data)
+		}
+
+		f, err := fs.Open("/data/cached.txt")
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		f.Close()
+
+		if _, err := fs.Stat("/data/new.txt"); err == nil {
+			t.Error("Stat() should be served by replica, which lacks the file")
+		}
+	})
+
+	t.Run("open with write flags goes to primary", func(t *testing.T) {
+		f, err := fs.OpenFile("/data/new.txt", os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile(O_RDWR) error = %v", err)
+		}
+		f.Close()
+
+		if _, err := fs.OpenFile("/data/cached.txt", os.O_WRONLY, 0); err == nil {
+			t.Error("OpenFile(O_WRONLY) should be served by primary, which lacks the file")
+		}
+	})
+
+	t.Run("nil read backend", func(t *testing.T) {
+		route := &Route{}
+		if err := WithReadBackend(nil)(route); err != ErrNilBackend {
+			t.Errorf("WithReadBackend(nil) error = %v, want ErrNilBackend", err)
+		}
+	})
+}
+
+func TestWithOperations(t *testing.T) {
+	landing, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+	main, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+
+	fs, err := New(
+		WithRoute("/ingest", landing, WithPriority(100), WithOperations(OpCreate)),
+		WithDefault(main),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	landing.MkdirAll("/ingest", 0755)
+	main.MkdirAll("/ingest", 0755)
+	writeTestFile(t, main, "/ingest/existing.txt", "on main")
+
+	t.Run("scoped operation uses route", func(t *testing.T) {
+		f, err := fs.Create("/ingest/upload.bin")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		f.Close()
+
+		if _, err := landing.Stat("/ingest/upload.bin"); err != nil {
+			t.Errorf("Create() not routed to landing backend: %v", err)
+		}
+	})
+
+	t.Run("other operations fall through", func(t *testing.T) {
+		if _, err := fs.Stat("/ingest/existing.txt"); err != nil {
+			t.Errorf("Stat() not routed to default backend: %v", err)
+		}
+		data, err := fs.ReadFile("/ingest/existing.txt")
+		if err != nil || string(data) != "on main" {
+			t.Errorf("ReadFile() = %q, %v", data, err)
+		}
+	})
+
+	t.Run("empty set handles all operations", func(t *testing.T) {
+		route := &Route{}
+		if err := WithOperations()(route); err != nil {
+			t.Fatalf("WithOperations() error = %v", err)
+		}
+		if !route.handles(OpStat) || !route.handles(OpCreate) {
+			t.Error("route without operations should handle every operation")
+		}
+	})
+}
+
+func TestRouteOperation(t *testing.T) {
+	writes := &mockFS{name: "writes"}
+	reads := &mockFS{name: "reads"}
+
+	r := NewRouter()
+	if err := r.AddRoute(Route{Pattern: "/data", Backend: writes, Operations: OpMutate}); err != nil {
+		t.Fatalf("AddRoute() error = %v", err)
+	}
+	if err := r.AddRoute(Route{Pattern: "/data", Backend: reads, Operations: OpRead}); err != nil {
+		t.Fatalf("AddRoute() with disjoint operations error = %v", err)
+	}
+
+	route, err := r.(OperationRouter).RouteOperation(OpTruncate, "/data/file")
+	if err != nil || route.Backend != writes {
+		t.Errorf("RouteOperation(OpTruncate) = %v, %v, want writes backend", route, err)
+	}
+	route, err = r.(OperationRouter).RouteOperation(OpReadDir, "/data/file")
+	if err != nil || route.Backend != reads {
+		t.Errorf("RouteOperation(OpReadDir) = %v, %v, want reads backend", route, err)
+	}
+
+	err = r.AddRoute(Route{Pattern: "/data", Backend: reads, Operations: OpStat | OpChmod})
+	if err != ErrDuplicateRoute {
+		t.Errorf("AddRoute() with overlapping operations error = %v, want ErrDuplicateRoute", err)
+	}
+	err = r.AddRoute(Route{Pattern: "/data", Backend: reads})
+	if err != ErrDuplicateRoute {
+		t.Errorf("AddRoute() for all operations error = %v, want ErrDuplicateRoute", err)
+	}
+}
+
+// basicRouter hides the optional methods of the default router, like a
+// custom Router implementing only the Router interface
+type basicRouter struct {
+	Router
+}
+
+func TestCustomRouterKeepsRouteState(t *testing.T) {
+	primary, _ := memfs.NewFS()
+	mirror, _ := memfs.NewFS()
+
+	fs, err := New(
+		WithRouter(basicRouter{NewRouter()}),
+		WithRoute("/data", primary, WithMirrors(mirror)),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, ok := fs.Router().(OperationRouter); ok {
+		t.Fatal("basicRouter should not implement OperationRouter")
+	}
+
+	if err := fs.MkdirAll("/data", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	writeTestFile(t, fs, "/data/file.txt", "mirrored")
+	for name, backend := range map[string]*memfs.FileSystem{"primary": primary, "mirror": mirror} {
+		if data, err := backend.ReadFile("/data/file.txt"); err != nil || string(data) != "mirrored" {
+			t.Errorf("%s backend = %q, %v", name, data, err)
+		}
+	}
+}
+
+// computedRouter routes by computing the backend of each path, without
+// registered routes
+type computedRouter struct {
+	hot, cold absfs.FileSystem
+}
+
+func (r *computedRouter) AddRoute(route Route) error { return nil }
+
+func (r *computedRouter) RemoveRoute(pattern string) error { return nil }
+
+func (r *computedRouter) Route(path string) (absfs.FileSystem, error) {
+	if strings.HasPrefix(path, "/cold") {
+		return r.cold, nil
+	}
+	return r.hot, nil
+}
+
+func (r *computedRouter) RouteWithInfo(path string, info os.FileInfo) (*Route, error) {
+	return nil, ErrNoRoute
+}
+
+func (r *computedRouter) Routes() []Route { return nil }
+
+func TestComputedRouter(t *testing.T) {
+	hot, _ := memfs.NewFS()
+	cold, _ := memfs.NewFS()
+
+	fs, err := New(WithRouter(&computedRouter{hot: hot, cold: cold}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	writeTestFile(t, fs, "/x", "hot")
+	if data, err := hot.ReadFile("/x"); err != nil || string(data) != "hot" {
+		t.Errorf("hot backend = %q, %v", data, err)
+	}
+
+	if err := fs.MkdirAll("/cold", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	writeTestFile(t, fs, "/cold/y", "cold")
+	if data, err := cold.ReadFile("/cold/y"); err != nil || string(data) != "cold" {
+		t.Errorf("cold backend = %q, %v", data, err)
+	}
+	if _, err := fs.Stat("/cold/y"); err != nil {
+		t.Errorf("Stat() error = %v", err)
+	}
+}
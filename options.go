@@ -1,6 +1,10 @@
 package switchfs
 
 import (
+	"os"
+	"regexp"
+	"time"
+
 	"github.com/absfs/absfs"
 )
 
@@ -36,7 +40,35 @@ func WithRoute(pattern string, backend absfs.FileSystem, opts ...RouteOption) Op
 			}
 		}
 
-		return fs.router.AddRoute(route)
+		fs.pending = append(fs.pending, route)
+		return nil
+	}
+}
+
+// WithShardedRoute adds a routing rule that spreads matching paths across
+// several backends by consistent hashing
+func WithShardedRoute(pattern string, shards []Shard, opts ...RouteOption) Option {
+	return func(fs *SwitchFS) error {
+		if len(shards) == 0 {
+			return ErrNilBackend
+		}
+
+		route := Route{
+			Pattern:  pattern,
+			Shards:   shards,
+			Priority: 0,
+			Type:     PatternPrefix,
+		}
+
+		// Apply route options
+		for _, opt := range opts {
+			if err := opt(&route); err != nil {
+				return err
+			}
+		}
+
+		fs.pending = append(fs.pending, route)
+		return nil
 	}
 }
 
@@ -56,6 +88,34 @@ func WithPatternType(pt PatternType) RouteOption {
 	}
 }
 
+// WithBalancedRoute adds a routing rule that spreads calls on matching paths
+// across equivalent backends. Files stay on the backend that opened them.
+func WithBalancedRoute(pattern string, policy BalancePolicy, targets []Target, opts ...RouteOption) Option {
+	return func(fs *SwitchFS) error {
+		if len(targets) == 0 {
+			return ErrNilBackend
+		}
+
+		route := Route{
+			Pattern:  pattern,
+			Targets:  targets,
+			Balance:  policy,
+			Priority: 0,
+			Type:     PatternPrefix,
+		}
+
+		// Apply route options
+		for _, opt := range opts {
+			if err := opt(&route); err != nil {
+				return err
+			}
+		}
+
+		fs.pending = append(fs.pending, route)
+		return nil
+	}
+}
+
 // WithFailover sets a failover backend
 func WithFailover(backend absfs.FileSystem) RouteOption {
 	return func(r *Route) error {
@@ -67,6 +127,183 @@ func WithFailover(backend absfs.FileSystem) RouteOption {
 	}
 }
 
+// WithHealthCooldown sets how long a failed target of a balanced route stays
+// out of rotation
+func WithHealthCooldown(d time.Duration) RouteOption {
+	return func(r *Route) error {
+		r.HealthCooldown = d
+		return nil
+	}
+}
+
+// WithReadBackend sets a backend serving read operations, such as a fast
+// replica or cache, while the route's backend serves mutating operations
+func WithReadBackend(backend absfs.FileSystem) RouteOption {
+	return func(r *Route) error {
+		if backend == nil {
+			return ErrNilBackend
+		}
+		r.ReadBackend = backend
+		return nil
+	}
+}
+
+// WithOperations limits the route to the given operations, so that other
+// operations on matching paths fall through to lower priority routes or the
+// default backend. With no operations the route handles all of them.
+func WithOperations(ops ...Operation) RouteOption {
+	return func(r *Route) error {
+		r.Operations = 0
+		for _, op := range ops {
+			r.Operations |= op
+		}
+		return nil
+	}
+}
+
+// WithMirrors fans every mutation on the route out to additional backends,
+// serving reads from the first healthy replica
+func WithMirrors(backends ...absfs.FileSystem) RouteOption {
+	return func(r *Route) error {
+		for _, backend := range backends {
+			if backend == nil {
+				return ErrNilBackend
+			}
+		}
+		r.Mirrors = append(r.Mirrors, backends...)
+		return nil
+	}
+}
+
+// WithWriteQuorum sets how many replicas of a mirrored route, or targets of
+// a balanced route, a mutation must succeed on
+func WithWriteQuorum(n int) RouteOption {
+	return func(r *Route) error {
+		if n < 1 {
+			return ErrInvalidQuorum
+		}
+		r.WriteQuorum = n
+		return nil
+	}
+}
+
+// WithDivergenceHandler sets a function called for each replica of a
+// mirrored route that failed a mutation which still met the write quorum
+func WithDivergenceHandler(fn func(Divergence)) RouteOption {
+	return func(r *Route) error {
+		r.OnDivergence = fn
+		return nil
+	}
+}
+
+// WithShardKey sets a regular expression whose first capture group selects
+// the part of the path hashed by a sharded route, such as a user ID. Without
+// it, the first path segment below the route's prefix is hashed.
+func WithShardKey(pattern string) RouteOption {
+	return func(r *Route) error {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return ErrInvalidPattern
+		}
+		r.ShardKey = pattern
+		return nil
+	}
+}
+
+// WithReadOnly rejects mutating operations on the route's paths, including
+// opens with write flags and renames into or out of the route
+func WithReadOnly() RouteOption {
+	return func(r *Route) error {
+		r.ReadOnly = true
+		return nil
+	}
+}
+
+// WithQuota caps the bytes and regular files stored under the route. Writes
+// that would exceed a limit fail with ErrQuotaExceeded. Zero means no limit.
+func WithQuota(maxBytes, maxFiles int64) RouteOption {
+	return func(r *Route) error {
+		if maxBytes < 0 || maxFiles < 0 {
+			return ErrInvalidQuota
+		}
+		r.MaxBytes = maxBytes
+		r.MaxFiles = maxFiles
+		return nil
+	}
+}
+
+// WithOpsLimit limits the calls dispatched to the route per second
+func WithOpsLimit(perSecond float64) RouteOption {
+	return func(r *Route) error {
+		if perSecond < 0 {
+			return ErrInvalidRateLimit
+		}
+		r.OpsPerSecond = perSecond
+		return nil
+	}
+}
+
+// WithBandwidthLimit limits the bytes read and written per second through
+// the route's files. Zero leaves a direction unlimited.
+func WithBandwidthLimit(readBytesPerSecond, writeBytesPerSecond int64) RouteOption {
+	return func(r *Route) error {
+		if readBytesPerSecond < 0 || writeBytesPerSecond < 0 {
+			return ErrInvalidRateLimit
+		}
+		r.ReadBytesPerSecond = readBytesPerSecond
+		r.WriteBytesPerSecond = writeBytesPerSecond
+		return nil
+	}
+}
+
+// WithNonBlockingRateLimit makes calls over the route's rate limits fail
+// with ErrRateLimited instead of waiting
+func WithNonBlockingRateLimit() RouteOption {
+	return func(r *Route) error {
+		r.RateLimitNoWait = true
+		return nil
+	}
+}
+
+// WithUmask clears permission bits of files and directories created under
+// the route
+func WithUmask(mask os.FileMode) RouteOption {
+	return func(r *Route) error {
+		r.Umask = mask & os.ModePerm
+		return nil
+	}
+}
+
+// WithDefaultModes sets the permissions of files and directories created
+// under the route, regardless of those the caller passes. Zero keeps the
+// caller's permissions.
+func WithDefaultModes(fileMode, dirMode os.FileMode) RouteOption {
+	return func(r *Route) error {
+		r.FileMode = fileMode & os.ModePerm
+		r.DirMode = dirMode & os.ModePerm
+		return nil
+	}
+}
+
+// WithChmodClamp limits Chmod on the route to the permission bits its
+// default modes and umask allow
+func WithChmodClamp() RouteOption {
+	return func(r *Route) error {
+		r.ClampChmod = true
+		return nil
+	}
+}
+
+// WithIDMap translates user and group IDs between the SwitchFS namespace
+// and the route's backend. Each map goes from namespace IDs to backend IDs
+// and may be nil.
+func WithIDMap(uids, gids map[int]int) RouteOption {
+	return func(r *Route) error {
+		r.UIDMap = uids
+		r.GIDMap = gids
+		return nil
+	}
+}
+
 // WithCondition sets a condition that must be met for routing
 func WithCondition(condition RouteCondition) RouteOption {
 	return func(r *Route) error {
@@ -91,6 +328,49 @@ func WithTempDir(dir string) Option {
 	}
 }
 
+// WithStatusInterval sets how long the health and capacity a backend reports
+// to conditions such as BackendFreeAbove is reused before the backend is
+// polled again
+func WithStatusInterval(d time.Duration) Option {
+	return func(fs *SwitchFS) error {
+		fs.status = newStatusCache(d)
+		return nil
+	}
+}
+
+// WithLinkCacheTTL sets how long the symbolic links found while resolving
+// paths are remembered before backends are asked again. Links changed on
+// backends directly are noticed once their entries expire; zero disables
+// the cache.
+func WithLinkCacheTTL(ttl time.Duration) Option {
+	return func(fs *SwitchFS) error {
+		fs.links = newLinkCache(ttl)
+		return nil
+	}
+}
+
+// WithProbing makes lookups of existing paths try the backend of every
+// route matching a path in priority order, then the default backend, and use
+// the first that holds the path. Dispatch conditions do not rule routes
+// out, as they choose where new files go. Paths found nowhere are dispatched
+// as usual, and remembered for negativeTTL so that they are not probed again;
+// zero disables the negative cache. Probing applies to the given operations,
+// or to read operations when none are given. Routes sharing a pattern are
+// allowed when probing is on, as probing tells them apart.
+func WithProbing(negativeTTL time.Duration, ops ...Operation) Option {
+	return func(fs *SwitchFS) error {
+		var set Operation
+		for _, op := range ops {
+			set |= op
+		}
+		if set == 0 {
+			set = OpRead
+		}
+		fs.probes = newProbeCache(negativeTTL, set)
+		return nil
+	}
+}
+
 // WithRouter sets a custom router implementation
 func WithRouter(router Router) Option {
 	return func(fs *SwitchFS) error {
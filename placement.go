@@ -0,0 +1,159 @@
+package switchfs
+
+import (
+	"io/fs"
+	"sort"
+
+	"github.com/absfs/absfs"
+)
+
+// placement is a route and the backend it serves a call with. A nil route
+// stands for the default backend. Placements are admitted when the route's
+// conditions hold for the call itself rather than only for files below it.
+type placement struct {
+	route    *Route
+	backend  absfs.FileSystem
+	admitted bool
+}
+
+// splits reports whether the route's condition splits the files under its
+// paths with the routes below it, choosing between them per file
+func (r *Route) splits() bool {
+	return r.sniffs || r.dispatched
+}
+
+// spreads reports whether files under a path may be spread over several
+// backends of a route or of the routes matching it, as on paths whose files
+// are routed by content or dispatch conditions and at the root of a sharded
+// route
+func (r *Route) spreads(name string) bool {
+	return r.splits() || (r.sharded != nil && r.sharded.spans(name))
+}
+
+// spread returns the backends files under a path may be placed on, with the
+// routes serving them: each route whose condition splits files, up to the
+// first that does not, or else the default backend. Routes with context
+// conditions only count when they admit the call, and sharded routes
+// contribute every shard at paths above their keyed trees. It returns nil
+// when files under the path all go to one backend.
+func (fs *SwitchFS) spread(op Operation, name string) ([]placement, error) {
+	var routes []*Route
+	var admitted []bool
+	for _, route := range fs.routesFor(op, name) {
+		ok := fs.admits(fs.ctx, route, name)
+		if !ok && !route.dispatched {
+			continue
+		}
+		routes = append(routes, route)
+		admitted = append(admitted, ok)
+		if !route.splits() {
+			break
+		}
+	}
+	if len(routes) == 0 || !routes[0].spreads(name) {
+		return nil, nil
+	}
+	if routes[len(routes)-1].splits() && fs.defaultFS != nil {
+		routes = append(routes, nil)
+		admitted = append(admitted, true)
+	}
+
+	var places []placement
+	for i, route := range routes {
+		if route != nil && route.sharded != nil && route.sharded.spans(name) {
+			for _, shard := range route.sharded.shards {
+				places = append(places, placement{route: route, backend: shard.Backend, admitted: admitted[i]})
+			}
+			continue
+		}
+		backend, err := fs.backendOf(route, op, name)
+		if err != nil {
+			if !admitted[i] {
+				continue
+			}
+			return nil, err
+		}
+		places = append(places, placement{route: route, backend: backend, admitted: admitted[i]})
+	}
+	return places, nil
+}
+
+// placements returns the placements of a path whose files are spread over
+// several backends, waiting for each route's operation rate limit.
+// Directories are not spread, so directory operations on these paths apply
+// to every backend their files may be placed on. It returns nil when files
+// under the path all go to one backend.
+func (fs *SwitchFS) placements(op Operation, name string) ([]placement, error) {
+	places, err := fs.spread(op, name)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range places {
+		if i > 0 && places[i-1].route == p.route {
+			continue
+		}
+		if err := fs.throttle(fs.ctx, p.route, op, name); err != nil {
+			return nil, err
+		}
+	}
+	if len(places) == 0 {
+		return nil, nil
+	}
+	return places, nil
+}
+
+// dirPlacements returns the placements of a spread path that hold a
+// directory there
+func dirPlacements(places []placement, name string) []placement {
+	var dirs []placement
+	for _, p := range places {
+		if info, err := p.backend.Stat(name); err == nil && info.IsDir() {
+			dirs = append(dirs, p)
+		}
+	}
+	return dirs
+}
+
+// spreadDir finds the placement holding a directory at a spread path, for
+// lookups the route serving the path could not find. It returns the given
+// route and backend when no other backend holds the directory.
+func (fs *SwitchFS) spreadDir(op Operation, name string, route *Route, backend absfs.FileSystem) (*Route, absfs.FileSystem) {
+	places, _ := fs.spread(op, name)
+	if dirs := dirPlacements(places, name); len(dirs) > 0 {
+		return dirs[0].route, dirs[0].backend
+	}
+	return route, backend
+}
+
+// readDirPlacements merges the listings of a directory on every backend of a
+// spread path. Entries on higher priority routes hide entries of the same
+// name below them.
+func readDirPlacements(places []placement, name string) ([]fs.DirEntry, error) {
+	var merged []fs.DirEntry
+	var firstErr error
+	found := false
+	seen := make(map[string]bool)
+	for _, p := range places {
+		entries, err := p.backend.ReadDir(name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		found = true
+		for _, entry := range idsFor(p.route).dirEntries(entries) {
+			if !seen[entry.Name()] {
+				seen[entry.Name()] = true
+				merged = append(merged, entry)
+			}
+		}
+	}
+	if !found {
+		return nil, firstErr
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Name() < merged[j].Name()
+	})
+	return merged, nil
+}
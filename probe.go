@@ -0,0 +1,144 @@
+package switchfs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxProbeMisses bounds the number of paths the negative cache remembers
+const maxProbeMisses = 4096
+
+// probeCache remembers paths that probing found on no backend, so that
+// repeated lookups of missing paths do not probe every backend again
+type probeCache struct {
+	ops   Operation
+	ttl   time.Duration
+	clock Clock
+
+	mu     sync.Mutex
+	misses map[string]time.Time
+}
+
+// newProbeCache creates the probing state for a set of operations
+func newProbeCache(ttl time.Duration, ops Operation) *probeCache {
+	return &probeCache{
+		ops:    ops,
+		ttl:    ttl,
+		clock:  SystemClock,
+		misses: make(map[string]time.Time),
+	}
+}
+
+// handles reports whether an operation probes
+func (c *probeCache) handles(op Operation) bool {
+	return c.ops&op != 0
+}
+
+// missed reports whether a path was recently found on no backend
+func (c *probeCache) missed(path string) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.misses[path]
+	if ok && c.clock.Now().After(expires) {
+		delete(c.misses, path)
+		return false
+	}
+	return ok
+}
+
+// remember records that a path was found on no backend
+func (c *probeCache) remember(path string) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if len(c.misses) >= maxProbeMisses {
+		for p, expires := range c.misses {
+			if now.After(expires) {
+				delete(c.misses, p)
+			}
+		}
+		if len(c.misses) >= maxProbeMisses {
+			clear(c.misses)
+		}
+	}
+	c.misses[path] = now.Add(c.ttl)
+}
+
+// invalidate drops remembered misses a mutation may create: the path
+// itself, or every path for renames, which may move whole trees
+func (c *probeCache) invalidate(op Operation, path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if op == OpRename {
+		clear(c.misses)
+		return
+	}
+	delete(c.misses, path)
+}
+
+// invalidateTree drops remembered misses at or below a path, which a copy
+// may create
+func (c *probeCache) invalidateTree(root string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for p := range c.misses {
+		if p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/") {
+			delete(c.misses, p)
+		}
+	}
+}
+
+// probeRoute finds the route whose backend holds an existing path, trying
+// each route matching it in priority order and then the default backend.
+// It reports false when no backend has the path, or when there is only one
+// candidate to try. The route is nil when the default backend holds the
+// path.
+func (fs *SwitchFS) probeRoute(ctx context.Context, op Operation, path string) (*Route, bool) {
+	routes := fs.routesFor(op, path)
+	candidates := len(routes)
+	if fs.defaultFS != nil {
+		candidates++
+	}
+	if candidates < 2 || fs.probes.missed(path) {
+		return nil, false
+	}
+
+	for _, route := range routes {
+		// Dispatch conditions choose where calls go, not where files are,
+		// so only context conditions rule routes out
+		if route.contextual && !fs.admits(ctx, route, path) {
+			continue
+		}
+		if route.sniffs {
+			if holdsContent(route, path) {
+				return route, true
+			}
+			continue
+		}
+		if _, err := lstatBackend(route.backendFor(op, path), path); err == nil {
+			return route, true
+		}
+	}
+	if fs.defaultFS != nil {
+		if _, err := lstatBackend(fs.defaultFS, path); err == nil {
+			return nil, true
+		}
+	}
+
+	if !op.IsWrite() {
+		fs.probes.remember(path)
+	}
+	return nil, false
+}
@@ -0,0 +1,195 @@
+package switchfs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/memfs"
+)
+
+// statCountingFS counts the Stat calls a backend serves
+type statCountingFS struct {
+	absfs.FileSystem
+	stats int
+}
+
+func (s *statCountingFS) Stat(name string) (os.FileInfo, error) {
+	s.stats++
+	return s.FileSystem.Stat(name)
+}
+
+func TestProbingLookup(t *testing.T) {
+	hotMem, _ := memfs.NewFS()
+	coldMem, _ := memfs.NewFS()
+	hot := &statCountingFS{FileSystem: hotMem}
+	cold := &statCountingFS{FileSystem: coldMem}
+	hotMem.MkdirAll("/data", 0755)
+	coldMem.MkdirAll("/data", 0755)
+
+	// Large files were moved to cold storage behind the SwitchFS's back
+	writeTestFile(t, hotMem, "/data/small.txt", "small")
+	writeTestFile(t, coldMem, "/data/large.bin", "large")
+
+	fs, err := New(
+		WithRoute("/data", hot, WithCondition(MaxSize(1<<20)), WithPriority(10)),
+		WithRoute("/data", cold, WithCondition(MinSize(1<<20))),
+		WithProbing(time.Minute, OpOpen, OpStat, OpReadFile),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	fs.probes.clock = ClockFunc(func() time.Time { return now })
+
+	for name, want := range map[string]string{"/data/small.txt": "small", "/data/large.bin": "large"} {
+		if _, err := fs.Stat(name); err != nil {
+			t.Errorf("Stat(%s) error = %v", name, err)
+		}
+		f, err := fs.Open(name)
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", name, err)
+		}
+		f.Close()
+		data, err := fs.ReadFile(name)
+		if err != nil || string(data) != want {
+			t.Errorf("ReadFile(%s) = %q, %v", name, data, err)
+		}
+	}
+
+	t.Run("missing paths are not-exist and cached", func(t *testing.T) {
+		if _, err := fs.Stat("/data/missing"); !os.IsNotExist(err) {
+			t.Fatalf("Stat() error = %v, want not exist", err)
+		}
+		hot.stats, cold.stats = 0, 0
+		for i := 0; i < 3; i++ {
+			fs.Stat("/data/missing")
+		}
+		if cold.stats != 0 {
+			t.Errorf("cold backend probed %d times within the negative TTL", cold.stats)
+		}
+
+		now = now.Add(2 * time.Minute)
+		fs.Stat("/data/missing")
+		if cold.stats != 1 {
+			t.Errorf("cold backend probed %d times after the TTL, want 1", cold.stats)
+		}
+	})
+
+	t.Run("creates invalidate the negative cache", func(t *testing.T) {
+		fs.Stat("/data/new.txt")
+		if !fs.probes.missed("/data/new.txt") {
+			t.Fatal("miss not remembered")
+		}
+		writeTestFile(t, fs, "/data/new.txt", "new")
+		if fs.probes.missed("/data/new.txt") {
+			t.Error("create left the miss remembered")
+		}
+		if _, err := fs.Stat("/data/new.txt"); err != nil {
+			t.Errorf("Stat() error = %v", err)
+		}
+	})
+
+	t.Run("unprobed operations dispatch as usual", func(t *testing.T) {
+		if err := fs.Chmod("/data/large.bin", 0600); !os.IsNotExist(err) {
+			t.Errorf("Chmod() error = %v, want not exist on the first route", err)
+		}
+	})
+}
+
+func TestProbingDefaultBackend(t *testing.T) {
+	routed, _ := memfs.NewFS()
+	def, _ := memfs.NewFS()
+	routed.MkdirAll("/data", 0755)
+	def.MkdirAll("/data", 0755)
+	writeTestFile(t, def, "/data/legacy.txt", "legacy")
+
+	fs, err := New(WithRoute("/data", routed), WithDefault(def), WithProbing(0, OpRead, OpRemove))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	data, err := fs.ReadFile("/data/legacy.txt")
+	if err != nil || string(data) != "legacy" {
+		t.Errorf("ReadFile() = %q, %v", data, err)
+	}
+
+	// Probing writes update files where they are
+	if err := fs.Remove("/data/legacy.txt"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := def.Stat("/data/legacy.txt"); !os.IsNotExist(err) {
+		t.Errorf("default Stat() error = %v, want not exist", err)
+	}
+
+	// New files still go to the route
+	writeTestFile(t, fs, "/data/new.txt", "new")
+	if _, err := routed.Stat("/data/new.txt"); err != nil {
+		t.Errorf("new file not on the route: %v", err)
+	}
+}
+
+func TestProbingOptions(t *testing.T) {
+	hot, _ := memfs.NewFS()
+	cold, _ := memfs.NewFS()
+	hot.MkdirAll("/data", 0755)
+	cold.MkdirAll("/data", 0755)
+
+	t.Run("duplicate routes need probing", func(t *testing.T) {
+		_, err := New(
+			WithRoute("/data", hot, WithCondition(MaxSize(1<<20))),
+			WithRoute("/data", cold, WithCondition(MinSize(1<<20))),
+		)
+		if err != ErrDuplicateRoute {
+			t.Errorf("New() without probing error = %v, want ErrDuplicateRoute", err)
+		}
+
+		// Probing may be enabled after the routes
+		_, err = New(
+			WithRoute("/data", hot, WithCondition(MaxSize(1<<20))),
+			WithRoute("/data", cold, WithCondition(MinSize(1<<20))),
+			WithProbing(0),
+		)
+		if err != nil {
+			t.Errorf("New() with probing error = %v", err)
+		}
+	})
+
+	fs, err := New(WithRoute("/data", hot, WithPriority(10)), WithRoute("/data", cold), WithProbing(time.Minute))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	writeTestFile(t, cold, "/data/old.txt", "old")
+
+	t.Run("reads are probed by default", func(t *testing.T) {
+		if _, err := fs.Stat("/data/old.txt"); err != nil {
+			t.Errorf("Stat() error = %v", err)
+		}
+		if err := fs.Chmod("/data/old.txt", 0600); !os.IsNotExist(err) {
+			t.Errorf("Chmod() error = %v, want not exist on the first route", err)
+		}
+	})
+
+	t.Run("copies invalidate misses below the destination", func(t *testing.T) {
+		if err := fs.MkdirAll("/data/src", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, fs, "/data/src/a.txt", "a")
+		fs.Stat("/data/dst/a.txt")
+		if !fs.probes.missed("/data/dst/a.txt") {
+			t.Fatal("miss not remembered")
+		}
+
+		if err := fs.CopyContext(context.Background(), "/data/src", "/data/dst", nil); err != nil {
+			t.Fatalf("CopyContext() error = %v", err)
+		}
+		if fs.probes.missed("/data/dst/a.txt") {
+			t.Error("copy left the miss remembered")
+		}
+		if _, err := fs.Stat("/data/dst/a.txt"); err != nil {
+			t.Errorf("Stat() error = %v", err)
+		}
+	})
+}
@@ -0,0 +1,395 @@
+package switchfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/absfs/absfs"
+)
+
+// quotaTracker accounts the bytes and regular files stored under a route.
+// Usage is seeded by scanning the route's backends on first use and kept up
+// to date by SwitchFS as files are written, truncated, removed and moved.
+type quotaTracker struct {
+	mu       sync.Mutex
+	maxBytes int64
+	maxFiles int64
+	bytes    int64
+	files    int64
+	seeded   bool
+	root     string
+	backends []absfs.FileSystem
+
+	// match limits usage to the paths a glob or regex route matches
+	match patternMatcher
+}
+
+// newQuotaTracker builds the quota tracker for a route with limits
+func newQuotaTracker(route *Route) *quotaTracker {
+	q := &quotaTracker{
+		maxBytes: route.MaxBytes,
+		maxFiles: route.MaxFiles,
+		root:     "/",
+	}
+	if route.Type == PatternPrefix && path.IsAbs(route.Pattern) {
+		q.root = path.Clean(route.Pattern)
+	} else {
+		q.match = route.compiled
+	}
+
+	// Each shard holds only its own paths, so all of them are scanned.
+	// Usage is counted where writes go, not on a read backend.
+	if route.sharded != nil {
+		for _, shard := range route.sharded.shards {
+			q.backends = append(q.backends, shard.Backend)
+		}
+	} else {
+		q.backends = []absfs.FileSystem{route.backendFor(OpCreate, q.root)}
+	}
+	return q
+}
+
+// seed scans the route's backends for existing usage. The caller holds q.mu.
+func (q *quotaTracker) seed() error {
+	if q.seeded {
+		return nil
+	}
+	for _, backend := range q.backends {
+		bytes, files, err := q.treeUsage(backend, q.root)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		q.bytes += bytes
+		q.files += files
+	}
+	q.seeded = true
+	return nil
+}
+
+// reserve adds usage, failing with ErrQuotaExceeded if a limit would be
+// exceeded. Reductions always succeed.
+func (q *quotaTracker) reserve(bytes, files int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.fits(bytes, files); err != nil {
+		return err
+	}
+	q.bytes = max(q.bytes+bytes, 0)
+	q.files = max(q.files+files, 0)
+	return nil
+}
+
+// check reports whether usage could be added without reserving it
+func (q *quotaTracker) check(bytes, files int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.fits(bytes, files)
+}
+
+// fits reports whether usage can be added. The caller holds q.mu.
+func (q *quotaTracker) fits(bytes, files int64) error {
+	if err := q.seed(); err != nil {
+		return err
+	}
+	if (bytes > 0 && q.maxBytes > 0 && q.bytes+bytes > q.maxBytes) ||
+		(files > 0 && q.maxFiles > 0 && q.files+files > q.maxFiles) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// release removes usage
+func (q *quotaTracker) release(bytes, files int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.seed() != nil {
+		return
+	}
+	q.bytes = max(q.bytes-bytes, 0)
+	q.files = max(q.files-files, 0)
+}
+
+// usage returns the current usage
+func (q *quotaTracker) usage() (bytes, files int64, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err = q.seed()
+	return q.bytes, q.files, err
+}
+
+// quotaError reports an operation rejected by a quota
+func quotaError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// treeUsage sums the usage of the regular files at or below name that
+// count against the quota
+func (q *quotaTracker) treeUsage(backend absfs.FileSystem, name string) (bytes, files int64, err error) {
+	if q.match == nil {
+		return treeUsage(backend, name)
+	}
+	return matchedUsage(backend, name, q.match)
+}
+
+// treeUsage sums the sizes and count of regular files at or below name,
+// without following symbolic links
+func treeUsage(backend absfs.FileSystem, name string) (bytes, files int64, err error) {
+	return matchedUsage(backend, name, nil)
+}
+
+// matchedUsage sums the sizes and count of regular files at or below name
+// whose paths match, or of all of them when match is nil
+func matchedUsage(backend absfs.FileSystem, name string, match patternMatcher) (bytes, files int64, err error) {
+	info, err := lstatBackend(backend, name)
+	if err != nil {
+		return 0, 0, err
+	}
+	if info.Mode().IsRegular() {
+		if match != nil && !match.Match(name) {
+			return 0, 0, nil
+		}
+		return info.Size(), 1, nil
+	}
+	if !info.IsDir() {
+		return 0, 0, nil
+	}
+
+	entries, err := backend.ReadDir(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, entry := range entries {
+		b, f, err := matchedUsage(backend, path.Join(name, entry.Name()), match)
+		if err != nil {
+			return 0, 0, err
+		}
+		bytes += b
+		files += f
+	}
+	return bytes, files, nil
+}
+
+// fileUsage returns the usage of a regular file at name that counts against
+// the quota, such as a file a rename or copy is about to overwrite
+func (q *quotaTracker) fileUsage(backend absfs.FileSystem, name string) (bytes, files int64) {
+	info, err := lstatBackend(backend, name)
+	if err != nil || !info.Mode().IsRegular() || (q.match != nil && !q.match.Match(name)) {
+		return 0, 0
+	}
+	return info.Size(), 1
+}
+
+// openFile opens a file on a quota-limited route, counting a created file
+// against the file limit and wrapping the result to account written bytes
+func (q *quotaTracker) openFile(backend absfs.FileSystem, name string, flag int, perm os.FileMode) (absfs.File, error) {
+	var size int64
+	info, err := backend.Stat(name)
+	exists := err == nil
+	if exists {
+		size = info.Size()
+	}
+
+	created := !exists && flag&os.O_CREATE != 0
+	if created {
+		if err := q.reserve(0, 1); err != nil {
+			return nil, quotaError("open", name, err)
+		}
+	}
+
+	f, err := backend.OpenFile(name, flag, perm)
+	if err != nil {
+		if created {
+			q.release(0, 1)
+		}
+		return nil, err
+	}
+	if exists && !info.Mode().IsRegular() {
+		return f, nil
+	}
+	if exists && flag&os.O_TRUNC != 0 {
+		q.release(size, 0)
+		size = 0
+	}
+	return &quotaFile{File: f, quota: q, size: size, append: flag&os.O_APPEND != 0}, nil
+}
+
+// truncate changes the size of a file, accounting the difference
+func (q *quotaTracker) truncate(backend absfs.FileSystem, name string, size int64) error {
+	info, err := backend.Stat(name)
+	if err != nil {
+		return backend.Truncate(name, size)
+	}
+
+	delta := size - info.Size()
+	if delta > 0 {
+		if err := q.reserve(delta, 0); err != nil {
+			return quotaError("truncate", name, err)
+		}
+	}
+	if err := backend.Truncate(name, size); err != nil {
+		if delta > 0 {
+			q.release(delta, 0)
+		}
+		return err
+	}
+	if delta < 0 {
+		q.release(-delta, 0)
+	}
+	return nil
+}
+
+// remove runs a removal, releasing the usage of the removed tree
+func (q *quotaTracker) remove(backend absfs.FileSystem, name string, removeFn func(string) error) error {
+	bytes, files, usageErr := q.treeUsage(backend, name)
+	if err := removeFn(name); err != nil {
+		return err
+	}
+	if usageErr == nil {
+		q.release(bytes, files)
+	}
+	return nil
+}
+
+// quotaFile accounts bytes written through a file on a quota-limited route
+type quotaFile struct {
+	absfs.File
+	quota  *quotaTracker
+	mu     sync.Mutex
+	size   int64
+	append bool
+}
+
+// grow reserves the bytes a write of n bytes at off would add
+func (f *quotaFile) grow(off int64, n int) (int64, error) {
+	growth := off + int64(n) - f.size
+	if growth <= 0 {
+		return 0, nil
+	}
+	if err := f.quota.reserve(growth, 0); err != nil {
+		return 0, quotaError("write", f.Name(), err)
+	}
+	return growth, nil
+}
+
+// settle returns the unused part of a reservation after a write of n bytes
+// at off
+func (f *quotaFile) settle(off int64, n int, reserved int64) {
+	end := off + int64(n)
+	used := max(end-f.size, 0)
+	if reserved > used {
+		f.quota.release(reserved-used, 0)
+	}
+	f.size = max(f.size, end)
+}
+
+func (f *quotaFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	off := f.size
+	if !f.append {
+		var err error
+		if off, err = f.File.Seek(0, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+	}
+	reserved, err := f.grow(off, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.File.Write(p)
+	f.settle(off, n, reserved)
+	return n, err
+}
+
+func (f *quotaFile) WriteAt(p []byte, off int64) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	reserved, err := f.grow(off, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n, err := f.File.WriteAt(p, off)
+	f.settle(off, n, reserved)
+	return n, err
+}
+
+func (f *quotaFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *quotaFile) Truncate(size int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delta := size - f.size
+	if delta > 0 {
+		if err := f.quota.reserve(delta, 0); err != nil {
+			return quotaError("truncate", f.Name(), err)
+		}
+	}
+	if err := f.File.Truncate(size); err != nil {
+		if delta > 0 {
+			f.quota.release(delta, 0)
+		}
+		return err
+	}
+	if delta < 0 {
+		f.quota.release(-delta, 0)
+	}
+	f.size = size
+	return nil
+}
+
+// transferQuota runs a rename or copy of the tree at oldpath on src to
+// newpath on dst, reserving its usage on the destination quota first. A
+// regular file the transfer overwrites gives its usage back to the
+// destination quota. For renames the usage is then released from the source
+// quota.
+func transferQuota(from, to *quotaTracker, src, dst absfs.FileSystem, op, oldpath, newpath string, rename bool, fn func() error) error {
+	if to == nil && (!rename || from == nil) {
+		return fn()
+	}
+
+	var overBytes, overFiles int64
+	if to != nil {
+		overBytes, overFiles = to.fileUsage(dst, newpath)
+	}
+	if rename && from == to {
+		if err := fn(); err != nil {
+			return err
+		}
+		to.release(overBytes, overFiles)
+		return nil
+	}
+
+	bytes, files, err := treeUsage(src, oldpath)
+	if err != nil {
+		// Let the transfer report the problem with the source
+		return fn()
+	}
+	if to != nil {
+		if err := to.reserve(bytes-overBytes, files-overFiles); err != nil {
+			return quotaError(op, oldpath, err)
+		}
+	}
+	if err := fn(); err != nil {
+		if to != nil {
+			to.release(bytes-overBytes, files-overFiles)
+		}
+		return err
+	}
+	if rename && from != nil {
+		from.release(bytes, files)
+	}
+	return nil
+}
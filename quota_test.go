@@ -0,0 +1,214 @@
+package switchfs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/absfs/memfs"
+)
+
+// newQuotaFS routes /tenant with a quota and /other without one
+func newQuotaFS(t *testing.T, maxBytes, maxFiles int64) (*SwitchFS, *memfs.FileSystem, *memfs.FileSystem) {
+	t.Helper()
+
+	tenant, _ := memfs.NewFS()
+	other, _ := memfs.NewFS()
+	tenant.MkdirAll("/tenant", 0755)
+	other.MkdirAll("/other", 0755)
+	writeTestFile(t, tenant, "/tenant/seed.txt", "0123456789")
+
+	fs, err := New(
+		WithRoute("/tenant", tenant, WithQuota(maxBytes, maxFiles)),
+		WithRoute("/other", other),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return fs, tenant, other
+}
+
+// wantUsage checks the usage tracked for a path's route
+func wantUsage(t *testing.T, fs *SwitchFS, name string, bytes, files int64) {
+	t.Helper()
+
+	route, err := fs.routeOperation(OpStat, name)
+	if err != nil {
+		t.Fatalf("RouteOperation() error = %v", err)
+	}
+	gotBytes, gotFiles, err := route.quota.usage()
+	if err != nil || gotBytes != bytes || gotFiles != files {
+		t.Errorf("usage = %d bytes, %d files, %v, want %d bytes, %d files", gotBytes, gotFiles, err, bytes, files)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	t.Run("seeded from backend", func(t *testing.T) {
+		fs, _, _ := newQuotaFS(t, 100, 10)
+		wantUsage(t, fs, "/tenant", 10, 1)
+	})
+
+	t.Run("writes within and over the byte limit", func(t *testing.T) {
+		fs, tenant, _ := newQuotaFS(t, 20, 10)
+
+		f, err := fs.Create("/tenant/a.txt")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		defer f.Close()
+		if _, err := f.Write([]byte("12345678")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		wantUsage(t, fs, "/tenant", 18, 2)
+
+		// Overwriting in place does not grow the file
+		if _, err := f.WriteAt([]byte("abcd"), 0); err != nil {
+			t.Fatalf("WriteAt() error = %v", err)
+		}
+
+		_, err = f.Write([]byte("xyz"))
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("Write() error = %v, want ErrQuotaExceeded", err)
+		}
+		var pathErr *os.PathError
+		if !errors.As(err, &pathErr) {
+			t.Errorf("Write() error = %T, want *os.PathError", err)
+		}
+		if data, _ := tenant.ReadFile("/tenant/a.txt"); string(data) != "abcd5678" {
+			t.Errorf("file = %q, rejected write reached the backend", data)
+		}
+		wantUsage(t, fs, "/tenant", 18, 2)
+
+		if err := f.Truncate(4); err != nil {
+			t.Fatalf("Truncate() error = %v", err)
+		}
+		wantUsage(t, fs, "/tenant", 14, 2)
+		if err := fs.Truncate("/tenant/a.txt", 100); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Truncate() error = %v, want ErrQuotaExceeded", err)
+		}
+	})
+
+	t.Run("file limit", func(t *testing.T) {
+		fs, _, _ := newQuotaFS(t, 0, 2)
+
+		f, err := fs.Create("/tenant/a.txt")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		f.Close()
+		if _, err := fs.Create("/tenant/b.txt"); !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("Create() error = %v, want ErrQuotaExceeded", err)
+		}
+
+		// Reopening an existing file does not count again
+		f, err = fs.Create("/tenant/a.txt")
+		if err != nil {
+			t.Fatalf("Create() existing error = %v", err)
+		}
+		f.Close()
+
+		if err := fs.Remove("/tenant/a.txt"); err != nil {
+			t.Fatalf("Remove() error = %v", err)
+		}
+		wantUsage(t, fs, "/tenant", 10, 1)
+		f, err = fs.Create("/tenant/b.txt")
+		if err != nil {
+			t.Fatalf("Create() after Remove error = %v", err)
+		}
+		f.Close()
+	})
+
+	t.Run("remove all releases the tree", func(t *testing.T) {
+		fs, _, _ := newQuotaFS(t, 100, 10)
+		fs.MkdirAll("/tenant/dir/sub", 0755)
+		f, _ := fs.Create("/tenant/dir/sub/a.txt")
+		f.Write([]byte("abc"))
+		f.Close()
+		wantUsage(t, fs, "/tenant", 13, 2)
+
+		if err := fs.RemoveAll("/tenant/dir"); err != nil {
+			t.Fatalf("RemoveAll() error = %v", err)
+		}
+		wantUsage(t, fs, "/tenant", 10, 1)
+	})
+
+	t.Run("cross-backend moves", func(t *testing.T) {
+		fs, _, other := newQuotaFS(t, 15, 10)
+		writeTestFile(t, other, "/other/big.txt", "0123456789")
+		writeTestFile(t, other, "/other/small.txt", "abc")
+
+		err := fs.Rename("/other/big.txt", "/tenant/big.txt")
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("Rename() error = %v, want ErrQuotaExceeded", err)
+		}
+		if _, err := other.Stat("/other/big.txt"); err != nil {
+			t.Errorf("source of rejected move gone: %v", err)
+		}
+
+		if err := fs.Rename("/other/small.txt", "/tenant/small.txt"); err != nil {
+			t.Fatalf("Rename() into route error = %v", err)
+		}
+		wantUsage(t, fs, "/tenant", 13, 2)
+
+		if err := fs.Rename("/tenant/seed.txt", "/other/seed.txt"); err != nil {
+			t.Fatalf("Rename() out of route error = %v", err)
+		}
+		wantUsage(t, fs, "/tenant", 3, 1)
+	})
+
+	t.Run("overwrites release the replaced file", func(t *testing.T) {
+		fs, _, other := newQuotaFS(t, 15, 10)
+		writeTestFile(t, other, "/other/a.txt", "abcdef")
+		if err := fs.CopyContext(context.Background(), "/other/a.txt", "/tenant/seed.txt", nil); err != nil {
+			t.Fatalf("CopyContext() error = %v", err)
+		}
+		wantUsage(t, fs, "/tenant", 6, 1)
+
+		writeTestFile(t, other, "/other/b.txt", "xy")
+		if err := fs.Rename("/other/b.txt", "/tenant/seed.txt"); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+		wantUsage(t, fs, "/tenant", 2, 1)
+	})
+
+	t.Run("usage counted on the write backend", func(t *testing.T) {
+		primary, _ := memfs.NewFS()
+		cache, _ := memfs.NewFS()
+		primary.MkdirAll("/data", 0755)
+		cache.MkdirAll("/data", 0755)
+		writeTestFile(t, primary, "/data/old.bin", string(make([]byte, 1000)))
+
+		fs, err := New(WithRoute("/data", primary, WithReadBackend(cache), WithQuota(1500, 0)))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		f, err := fs.Create("/data/new.bin")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		defer f.Close()
+		if _, err := f.Write(make([]byte, 1000)); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("Write() error = %v, want ErrQuotaExceeded", err)
+		}
+	})
+
+	t.Run("glob routes count matching files only", func(t *testing.T) {
+		mem, _ := memfs.NewFS()
+		mem.MkdirAll("/logs", 0755)
+		writeTestFile(t, mem, "/logs/app.log", "0123456789")
+		writeTestFile(t, mem, "/logs/notes.txt", "01234567890123456789")
+
+		fs, err := New(WithRoute("/logs/*.log", mem, WithPatternType(PatternGlob), WithQuota(100, 10)))
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		wantUsage(t, fs, "/logs/app.log", 10, 1)
+	})
+
+	t.Run("invalid quota", func(t *testing.T) {
+		if err := WithQuota(-1, 0)(&Route{}); err != ErrInvalidQuota {
+			t.Errorf("WithQuota(-1, 0) error = %v, want ErrInvalidQuota", err)
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package switchfs
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/absfs/absfs"
+)
+
+// tokenBucket is a token-bucket rate limiter. Tokens accrue at rate per
+// second up to burst; callers may overdraw the bucket and then wait for the
+// balance to recover.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// newTokenBucket creates a full bucket
+func newTokenBucket(rate float64) *tokenBucket {
+	burst := max(rate, 1)
+	return &tokenBucket{
+		rate:   rate,
+		burst:  burst,
+		tokens: burst,
+		now:    time.Now,
+	}
+}
+
+// take removes n tokens and returns how long to wait until they are paid
+// for. With noWait nothing is taken and ok is false unless the tokens are
+// available now.
+func (b *tokenBucket) take(n float64, noWait bool) (wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	if noWait && b.tokens < n {
+		return 0, false
+	}
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0, true
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second)), true
+}
+
+// refund returns tokens that were taken but not used
+func (b *tokenBucket) refund(n float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+n)
+}
+
+// chunk returns the largest amount a single take should request
+func (b *tokenBucket) chunk() int {
+	return int(b.burst)
+}
+
+// routeLimits holds the rate limiters of a route
+type routeLimits struct {
+	ops    *tokenBucket
+	read   *tokenBucket
+	write  *tokenBucket
+	noWait bool
+}
+
+// newRouteLimits builds the rate limiters for a route with limits
+func newRouteLimits(route *Route) *routeLimits {
+	l := &routeLimits{noWait: route.RateLimitNoWait}
+	if route.OpsPerSecond > 0 {
+		l.ops = newTokenBucket(route.OpsPerSecond)
+	}
+	if route.ReadBytesPerSecond > 0 {
+		l.read = newTokenBucket(float64(route.ReadBytesPerSecond))
+	}
+	if route.WriteBytesPerSecond > 0 {
+		l.write = newTokenBucket(float64(route.WriteBytesPerSecond))
+	}
+	return l
+}
+
+// wait takes n tokens from a bucket, waiting until they are paid for or ctx
+// is done. In non-blocking mode it fails with ErrRateLimited instead.
+func (l *routeLimits) wait(ctx context.Context, b *tokenBucket, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+
+	wait, ok := b.take(float64(n), l.noWait)
+	if !ok {
+		return ErrRateLimited
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		b.refund(float64(n))
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// transfer pays for n bytes in chunks no larger than the bucket's burst
+func (l *routeLimits) transfer(ctx context.Context, b *tokenBucket, n int) error {
+	if b == nil {
+		return nil
+	}
+	for n > 0 {
+		size := min(n, b.chunk())
+		if err := l.wait(ctx, b, size); err != nil {
+			return err
+		}
+		n -= size
+	}
+	return nil
+}
+
+// rateError reports an operation stopped by a rate limit or by its context
+func rateError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// rateFile limits the read and write bandwidth of a file on a rate-limited
+// route
+type rateFile struct {
+	absfs.File
+	ctx    context.Context
+	limits *routeLimits
+}
+
+// limitFile wraps a file when its route limits bandwidth
+func limitFile(ctx context.Context, limits *routeLimits, f absfs.File) absfs.File {
+	if limits == nil || (limits.read == nil && limits.write == nil) {
+		return f
+	}
+	return &rateFile{File: f, ctx: ctx, limits: limits}
+}
+
+// readLimited pays for up to len(p) bytes before reading and refunds what
+// was not read
+func (f *rateFile) readLimited(p []byte, read func([]byte) (int, error)) (int, error) {
+	b := f.limits.read
+	if b == nil {
+		return read(p)
+	}
+	if len(p) > b.chunk() {
+		p = p[:b.chunk()]
+	}
+	if err := f.limits.wait(f.ctx, b, len(p)); err != nil {
+		return 0, rateError("read", f.Name(), err)
+	}
+	n, err := read(p)
+	if n < len(p) {
+		b.refund(float64(len(p) - n))
+	}
+	return n, err
+}
+
+func (f *rateFile) Read(p []byte) (int, error) {
+	return f.readLimited(p, f.File.Read)
+}
+
+func (f *rateFile) ReadAt(p []byte, off int64) (int, error) {
+	return f.readLimited(p, func(p []byte) (int, error) {
+		return f.File.ReadAt(p, off)
+	})
+}
+
+// writeLimited writes p in chunks, paying for each before writing it
+func (f *rateFile) writeLimited(p []byte, write func([]byte, int64) (int, error)) (int, error) {
+	b := f.limits.write
+	if b == nil {
+		return write(p, 0)
+	}
+
+	var written int
+	for written < len(p) {
+		size := min(len(p)-written, b.chunk())
+		if err := f.limits.wait(f.ctx, b, size); err != nil {
+			return written, rateError("write", f.Name(), err)
+		}
+		n, err := write(p[written:written+size], int64(written))
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (f *rateFile) Write(p []byte) (int, error) {
+	return f.writeLimited(p, func(chunk []byte, _ int64) (int, error) {
+		return f.File.Write(chunk)
+	})
+}
+
+func (f *rateFile) WriteAt(p []byte, off int64) (int, error) {
+	return f.writeLimited(p, func(chunk []byte, done int64) (int, error) {
+		return f.File.WriteAt(chunk, off+done)
+	})
+}
+
+func (f *rateFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
@@ -0,0 +1,132 @@
+package switchfs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/absfs/memfs"
+)
+
+// newLimitedFS routes /data to a memfs backend with the given route options
+func newLimitedFS(t *testing.T, opts ...RouteOption) *SwitchFS {
+	t.Helper()
+
+	mem, _ := memfs.NewFS()
+	mem.MkdirAll("/data", 0755)
+	writeTestFile(t, mem, "/data/file.txt", strings.Repeat("x", 100))
+
+	fs, err := New(WithRoute("/data", mem, opts...))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return fs
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(10)
+	b.now = func() time.Time { return now }
+
+	if wait, ok := b.take(10, false); !ok || wait != 0 {
+		t.Errorf("take(10) on full bucket = %v, %v", wait, ok)
+	}
+	if _, ok := b.take(1, true); ok {
+		t.Error("non-blocking take on empty bucket succeeded")
+	}
+	if wait, _ := b.take(5, false); wait != 500*time.Millisecond {
+		t.Errorf("take(5) on empty bucket wait = %v, want 500ms", wait)
+	}
+
+	now = now.Add(time.Second)
+	if wait, ok := b.take(5, true); !ok || wait != 0 {
+		t.Errorf("take(5) after refill = %v, %v", wait, ok)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("non-blocking ops limit", func(t *testing.T) {
+		fs := newLimitedFS(t, WithOpsLimit(1), WithNonBlockingRateLimit())
+
+		if _, err := fs.Stat("/data/file.txt"); err != nil {
+			t.Fatalf("first Stat() error = %v", err)
+		}
+		if _, err := fs.Stat("/data/file.txt"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("second Stat() error = %v, want ErrRateLimited", err)
+		}
+	})
+
+	t.Run("blocking ops limit waits", func(t *testing.T) {
+		fs := newLimitedFS(t, WithOpsLimit(20))
+
+		start := time.Now()
+		for i := 0; i < 22; i++ {
+			if _, err := fs.Stat("/data/file.txt"); err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+			t.Errorf("22 calls at 20/s took %v, want about 100ms", elapsed)
+		}
+	})
+
+	t.Run("wait bounded by context", func(t *testing.T) {
+		fs := newLimitedFS(t, WithOpsLimit(1))
+		fs.Stat("/data/file.txt")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := fs.RenameContext(ctx, "/data/file.txt", "/data/moved.txt", nil)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("RenameContext() error = %v, want DeadlineExceeded", err)
+		}
+	})
+
+	t.Run("write bandwidth", func(t *testing.T) {
+		fs := newLimitedFS(t, WithBandwidthLimit(0, 10000))
+
+		f, err := fs.Create("/data/out.bin")
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		defer f.Close()
+
+		start := time.Now()
+		n, err := f.Write(make([]byte, 12000))
+		if err != nil || n != 12000 {
+			t.Fatalf("Write() = %d, %v", n, err)
+		}
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+			t.Errorf("12000 bytes at 10000 B/s took %v, want about 200ms", elapsed)
+		}
+	})
+
+	t.Run("non-blocking bandwidth", func(t *testing.T) {
+		fs := newLimitedFS(t, WithBandwidthLimit(100, 0), WithNonBlockingRateLimit())
+
+		f, err := fs.Open("/data/file.txt")
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		defer f.Close()
+
+		buf := make([]byte, 100)
+		if n, err := f.Read(buf); err != nil || n != 100 {
+			t.Fatalf("Read() = %d, %v", n, err)
+		}
+		if _, err := fs.ReadFile("/data/file.txt"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("ReadFile() error = %v, want ErrRateLimited", err)
+		}
+	})
+
+	t.Run("invalid limits", func(t *testing.T) {
+		if err := WithOpsLimit(-1)(&Route{}); err != ErrInvalidRateLimit {
+			t.Errorf("WithOpsLimit(-1) error = %v, want ErrInvalidRateLimit", err)
+		}
+		if err := WithBandwidthLimit(0, -1)(&Route{}); err != ErrInvalidRateLimit {
+			t.Errorf("WithBandwidthLimit(0, -1) error = %v, want ErrInvalidRateLimit", err)
+		}
+	})
+}
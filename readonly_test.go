@@ -0,0 +1,83 @@
+package switchfs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"testing"
+
+	"github.com/absfs/memfs"
+)
+
+func TestReadOnlyRoute(t *testing.T) {
+	archive, _ := memfs.NewFS()
+	scratch, _ := memfs.NewFS()
+	archive.MkdirAll("/archive/dir", 0755)
+	writeTestFile(t, archive, "/archive/file.txt", "frozen")
+	scratch.MkdirAll("/scratch", 0755)
+	writeTestFile(t, scratch, "/scratch/file.txt", "scratch")
+
+	fs, err := New(
+		WithRoute("/archive", archive, WithReadOnly()),
+		WithRoute("/scratch", scratch),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// wantPermission checks that err is a PathError wrapping ErrPermission
+	wantPermission := func(t *testing.T, name string, err error) {
+		t.Helper()
+		var pathErr *os.PathError
+		if !errors.Is(err, iofs.ErrPermission) || !errors.As(err, &pathErr) {
+			t.Errorf("%s error = %v, want PathError wrapping ErrPermission", name, err)
+		}
+	}
+
+	t.Run("reads allowed", func(t *testing.T) {
+		data, err := fs.ReadFile("/archive/file.txt")
+		if err != nil || string(data) != "frozen" {
+			t.Errorf("ReadFile() = %q, %v", data, err)
+		}
+		f, err := fs.Open("/archive/file.txt")
+		if err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		f.Close()
+		if _, err := fs.ReadDir("/archive"); err != nil {
+			t.Errorf("ReadDir() error = %v", err)
+		}
+	})
+
+	t.Run("mutations rejected", func(t *testing.T) {
+		_, err := fs.Create("/archive/new.txt")
+		wantPermission(t, "Create()", err)
+		_, err = fs.OpenFile("/archive/file.txt", os.O_WRONLY, 0)
+		wantPermission(t, "OpenFile(O_WRONLY)", err)
+		_, err = fs.OpenFile("/archive/file.txt", os.O_RDONLY|os.O_APPEND, 0)
+		wantPermission(t, "OpenFile(O_APPEND)", err)
+		wantPermission(t, "Mkdir()", fs.Mkdir("/archive/new", 0755))
+		wantPermission(t, "MkdirAll()", fs.MkdirAll("/archive/a/b", 0755))
+		wantPermission(t, "Remove()", fs.Remove("/archive/file.txt"))
+		wantPermission(t, "RemoveAll()", fs.RemoveAll("/archive/dir"))
+		wantPermission(t, "Chmod()", fs.Chmod("/archive/file.txt", 0600))
+		wantPermission(t, "Truncate()", fs.Truncate("/archive/file.txt", 0))
+		wantPermission(t, "Symlink()", fs.Symlink("/archive/file.txt", "/archive/link"))
+		wantPermission(t, "Rename()", fs.Rename("/archive/file.txt", "/archive/moved.txt"))
+	})
+
+	t.Run("cross-backend renames rejected", func(t *testing.T) {
+		wantPermission(t, "Rename() out of route", fs.Rename("/archive/file.txt", "/scratch/stolen.txt"))
+		wantPermission(t, "Rename() into route", fs.Rename("/scratch/file.txt", "/archive/file2.txt"))
+
+		if _, err := scratch.Stat("/scratch/stolen.txt"); !os.IsNotExist(err) {
+			t.Errorf("destination of rejected rename exists: %v", err)
+		}
+		if _, err := scratch.Stat("/scratch/file.txt"); err != nil {
+			t.Errorf("source of rejected rename gone: %v", err)
+		}
+		if data, err := archive.ReadFile("/archive/file.txt"); err != nil || string(data) != "frozen" {
+			t.Errorf("archive file = %q, %v", data, err)
+		}
+	})
+}
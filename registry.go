@@ -0,0 +1,168 @@
+package switchfs
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// registry holds the conditions and rewriters registered by name
+var registry = struct {
+	sync.RWMutex
+	conditions map[string]RouteCondition
+	rewriters  map[string]PathRewriter
+}{
+	conditions: make(map[string]RouteCondition),
+	rewriters:  make(map[string]PathRewriter),
+}
+
+// namedCondition is a condition printed by name
+type namedCondition struct {
+	name      string
+	condition RouteCondition
+}
+
+func (c *namedCondition) Evaluate(path string, info os.FileInfo) bool {
+	return c.condition.Evaluate(path, info)
+}
+
+func (c *namedCondition) EvaluateContent(path string, info os.FileInfo, head []byte) bool {
+	return evaluateContent(c.condition, path, info, head)
+}
+
+func (c *namedCondition) EvaluateContext(ctx context.Context, path string, info os.FileInfo) bool {
+	return evaluateContext(ctx, c.condition, path, info)
+}
+
+func (c *namedCondition) String() string {
+	return c.name
+}
+
+// NamedCondition wraps a condition so that it prints as name
+func NamedCondition(name string, condition RouteCondition) RouteCondition {
+	return &namedCondition{name: name, condition: condition}
+}
+
+// namedRewriter is a rewriter printed by name
+type namedRewriter struct {
+	name     string
+	rewriter PathRewriter
+}
+
+func (r *namedRewriter) Rewrite(path string) string {
+	return r.rewriter.Rewrite(path)
+}
+
+func (r *namedRewriter) String() string {
+	return r.name
+}
+
+// NamedRewriter wraps a rewriter so that it prints as name
+func NamedRewriter(name string, rewriter PathRewriter) PathRewriter {
+	return &namedRewriter{name: name, rewriter: rewriter}
+}
+
+// RegisterCondition registers a condition under a name, so that declarative
+// configuration can refer to it. LookupCondition returns it wrapped to print
+// as its name.
+func RegisterCondition(name string, condition RouteCondition) error {
+	if name == "" || condition == nil {
+		return ErrInvalidName
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.conditions[name]; ok {
+		return ErrDuplicateName
+	}
+	registry.conditions[name] = NamedCondition(name, condition)
+	return nil
+}
+
+// LookupCondition returns the condition registered under a name
+func LookupCondition(name string) (RouteCondition, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	condition, ok := registry.conditions[name]
+	if !ok {
+		return nil, ErrUnknownName
+	}
+	return condition, nil
+}
+
+// RegisteredConditions returns the names of the registered conditions, sorted
+func RegisteredConditions() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.conditions))
+	for name := range registry.conditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RegisterRewriter registers a rewriter under a name, so that declarative
+// configuration can refer to it. LookupRewriter returns it wrapped to print
+// as its name.
+func RegisterRewriter(name string, rewriter PathRewriter) error {
+	if name == "" || rewriter == nil {
+		return ErrInvalidName
+	}
+
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, ok := registry.rewriters[name]; ok {
+		return ErrDuplicateName
+	}
+	registry.rewriters[name] = NamedRewriter(name, rewriter)
+	return nil
+}
+
+// LookupRewriter returns the rewriter registered under a name
+func LookupRewriter(name string) (PathRewriter, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	rewriter, ok := registry.rewriters[name]
+	if !ok {
+		return nil, ErrUnknownName
+	}
+	return rewriter, nil
+}
+
+// RegisteredRewriters returns the names of the registered rewriters, sorted
+func RegisteredRewriters() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.rewriters))
+	for name := range registry.rewriters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// funcName returns the package-qualified name of a function, such as
+// "main.isArchive"
+func funcName(f any) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return "nil"
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return "func"
+	}
+	name := fn.Name()
+	return name[strings.LastIndex(name, "/")+1:]
+}
@@ -0,0 +1,151 @@
+package switchfs
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/absfs/memfs"
+)
+
+// isArchive is a custom condition function
+func isArchive(path string, info os.FileInfo) bool {
+	return strings.HasSuffix(path, ".tar")
+}
+
+func TestConditionString(t *testing.T) {
+	glob, _ := NameGlob("report-*.csv")
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		cond RouteCondition
+		want string
+	}{
+		{And(MinSize(1<<20), Not(DirectoriesOnly())), "And(MinSize(1MiB), Not(DirectoriesOnly()))"},
+		{Or(MaxSize(1500), FilesOnly()), "Or(MaxSize(1500), FilesOnly())"},
+		{SizeRange(4096, 3<<30), "SizeRange(4KiB, 3GiB)"},
+		{NewerThan(since), "NewerThan(2024-01-01T00:00:00Z)"},
+		{ModifiedOlderThan(48 * time.Hour), "ModifiedOlderThan(48h0m0s)"},
+		{AccessedNewerThan(time.Hour), "AccessedNewerThan(1h0m0s)"},
+		{ModifiedWithin(time.Hour, 2*time.Hour), "ModifiedWithin(1h0m0s, 2h0m0s)"},
+		{HasExtension("JPG", ".tar.gz"), `HasExtension("jpg", "tar.gz")`},
+		{glob, `NameGlob("report-*.csv")`},
+		{PathDepth(1, 3), "PathDepth(1, 3)"},
+		{Hidden(), "Hidden()"},
+		{Executable(), "Executable()"},
+		{Symlink(), "Symlink()"},
+		{HasMode(os.ModeSetuid | 0o100), "HasMode(u--x------)"},
+		{HasAnyMode(0o6), "HasAnyMode(-------rw-)"},
+		{OwnedBy(0, 1000), "OwnedBy(0, 1000)"},
+		{InGroup(100), "InGroup(100)"},
+		{ContentType("image/*"), `ContentType("image/*")`},
+		{Tenant("acme"), `Tenant("acme")`},
+		{HasRole("admin"), `HasRole("admin")`},
+		{ConditionFunc(isArchive), "ConditionFunc(switchfs.isArchive)"},
+		{NamedCondition("archives", ConditionFunc(isArchive)), "archives"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := describe(tt.cond); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriterString(t *testing.T) {
+	re, _ := RegexRewrite(`^/v\d+`, "/api")
+
+	tests := []struct {
+		rewriter PathRewriter
+		want     string
+	}{
+		{StripPrefix("/api"), `StripPrefix("/api")`},
+		{AddPrefix("/data"), `AddPrefix("/data")`},
+		{ReplacePrefix("/old", "/new"), `ReplacePrefix("/old", "/new")`},
+		{re, `RegexRewrite("^/v\\d+", "/api")`},
+		{ChainRewriters(StripPrefix("/a"), AddPrefix("/b")), `ChainRewriters(StripPrefix("/a"), AddPrefix("/b"))`},
+		{StaticMapping(map[string]string{"/b": "/y", "/a": "/x"}), `StaticMapping({"/a": "/x", "/b": "/y"})`},
+		{RewriterFunc(strings.ToLower), "RewriterFunc(strings.ToLower)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := describe(tt.rewriter); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	if err := RegisterCondition("test-archives", ConditionFunc(isArchive)); err != nil {
+		t.Fatalf("RegisterCondition() error = %v", err)
+	}
+	if err := RegisterCondition("test-archives", Hidden()); err != ErrDuplicateName {
+		t.Errorf("RegisterCondition() duplicate error = %v, want ErrDuplicateName", err)
+	}
+	if err := RegisterCondition("", Hidden()); err != ErrInvalidName {
+		t.Errorf("RegisterCondition() empty name error = %v, want ErrInvalidName", err)
+	}
+	if err := RegisterRewriter("test-lower", RewriterFunc(strings.ToLower)); err != nil {
+		t.Fatalf("RegisterRewriter() error = %v", err)
+	}
+
+	cond, err := LookupCondition("test-archives")
+	if err != nil {
+		t.Fatalf("LookupCondition() error = %v", err)
+	}
+	if !cond.Evaluate("/backup.tar", nil) || cond.Evaluate("/notes.txt", nil) {
+		t.Error("registered condition evaluates differently")
+	}
+	if got := describe(And(cond, MinSize(1))); got != "And(test-archives, MinSize(1))" {
+		t.Errorf("String() = %q", got)
+	}
+	if _, err := LookupCondition("test-missing"); err != ErrUnknownName {
+		t.Errorf("LookupCondition() error = %v, want ErrUnknownName", err)
+	}
+
+	rewriter, err := LookupRewriter("test-lower")
+	if err != nil || rewriter.Rewrite("/A/B") != "/a/b" {
+		t.Errorf("LookupRewriter() = %v, %v", rewriter, err)
+	}
+
+	found := false
+	for _, name := range RegisteredConditions() {
+		found = found || name == "test-archives"
+	}
+	if !found {
+		t.Errorf("RegisteredConditions() = %v, missing test-archives", RegisteredConditions())
+	}
+
+	t.Run("named conditions keep context and content", func(t *testing.T) {
+		tenant := NamedCondition("acme-only", Tenant("acme"))
+		if !usesContext(tenant) || usesContent(tenant) {
+			t.Error("named condition hides what it inspects")
+		}
+		ctx := ContextWithTenant(context.Background(), "globex")
+		if evaluateContext(ctx, tenant, "/x", nil) {
+			t.Error("named context condition matched another tenant")
+		}
+	})
+
+	t.Run("routes use registered conditions", func(t *testing.T) {
+		cold, _ := memfs.NewFS()
+		hot, _ := memfs.NewFS()
+		fs, err := New(
+			WithRoute("/", cold, WithCondition(cond), WithRewriter(rewriter)),
+			WithDefault(hot),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		route := fs.Router().Routes()[0]
+		if describe(route.Condition) != "test-archives" || describe(route.Rewriter) != "test-lower" {
+			t.Errorf("route condition = %v, rewriter = %v", route.Condition, route.Rewriter)
+		}
+	})
+}
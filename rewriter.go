@@ -1,10 +1,25 @@
 package switchfs
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
+// RewriterFunc adapts a function to the PathRewriter interface
+type RewriterFunc func(path string) string
+
+// Rewrite returns f(path)
+func (f RewriterFunc) Rewrite(path string) string {
+	return f(path)
+}
+
+// String returns the name of the function
+func (f RewriterFunc) String() string {
+	return "RewriterFunc(" + funcName(f) + ")"
+}
+
 // prefixRewriter adds or removes a prefix from paths
 type prefixRewriter struct {
 	oldPrefix string
@@ -26,6 +41,17 @@ func (r *prefixRewriter) Rewrite(path string) string {
 	return path
 }
 
+func (r *prefixRewriter) String() string {
+	switch {
+	case r.oldPrefix == "":
+		return fmt.Sprintf("AddPrefix(%q)", r.newPrefix)
+	case r.newPrefix == "":
+		return fmt.Sprintf("StripPrefix(%q)", r.oldPrefix)
+	default:
+		return fmt.Sprintf("ReplacePrefix(%q, %q)", r.oldPrefix, r.newPrefix)
+	}
+}
+
 // StripPrefix creates a rewriter that removes a prefix from paths
 func StripPrefix(prefix string) PathRewriter {
 	return &prefixRewriter{oldPrefix: prefix, newPrefix: ""}
@@ -51,6 +77,10 @@ func (r *regexRewriter) Rewrite(path string) string {
 	return r.pattern.ReplaceAllString(path, r.replacement)
 }
 
+func (r *regexRewriter) String() string {
+	return fmt.Sprintf("RegexRewrite(%q, %q)", r.pattern.String(), r.replacement)
+}
+
 // RegexRewrite creates a rewriter that uses regex patterns
 func RegexRewrite(pattern, replacement string) (PathRewriter, error) {
 	re, err := regexp.Compile(pattern)
@@ -72,6 +102,14 @@ func (r *chainRewriter) Rewrite(path string) string {
 	return path
 }
 
+func (r *chainRewriter) String() string {
+	parts := make([]string, len(r.rewriters))
+	for i, rewriter := range r.rewriters {
+		parts[i] = describe(rewriter)
+	}
+	return "ChainRewriters(" + strings.Join(parts, ", ") + ")"
+}
+
 // ChainRewriters creates a rewriter that applies multiple rewriters in order
 func ChainRewriters(rewriters ...PathRewriter) PathRewriter {
 	return &chainRewriter{rewriters: rewriters}
@@ -89,6 +127,20 @@ func (r *staticRewriter) Rewrite(path string) string {
 	return path
 }
 
+func (r *staticRewriter) String() string {
+	from := make([]string, 0, len(r.mapping))
+	for path := range r.mapping {
+		from = append(from, path)
+	}
+	sort.Strings(from)
+
+	parts := make([]string, len(from))
+	for i, path := range from {
+		parts[i] = fmt.Sprintf("%q: %q", path, r.mapping[path])
+	}
+	return "StaticMapping({" + strings.Join(parts, ", ") + "})"
+}
+
 // StaticMapping creates a rewriter with a static path mapping
 func StaticMapping(mapping map[string]string) PathRewriter {
 	return &staticRewriter{mapping: mapping}
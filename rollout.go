@@ -0,0 +1,99 @@
+package switchfs
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sync/atomic"
+)
+
+// rolloutBuckets is the number of hash buckets paths are spread over, so
+// rollouts can be set in steps of 0.0001%
+const rolloutBuckets = 1_000_000
+
+// RolloutCondition matches a stable percentage of paths, chosen by hashing
+// each path, or the part of it a key pattern captures, into a bucket. Raising
+// the percentage only adds paths, so a route can be moved onto a new backend
+// a step at a time. The percentage may be changed while the condition is in
+// use; rollouts are DispatchConditions, so SwitchFS evaluates them on every
+// call.
+type RolloutCondition struct {
+	key  *regexp.Regexp
+	salt string
+
+	// buckets is the number of buckets that match
+	buckets atomic.Int64
+}
+
+// Rollout creates a condition that matches percent percent of paths
+func Rollout(percent float64) *RolloutCondition {
+	c := &RolloutCondition{}
+	c.SetPercent(percent)
+	return c
+}
+
+// RolloutByKey creates a condition like Rollout that hashes the first capture
+// group of a regular expression, such as a user ID, so that all paths sharing
+// the key move together. Paths the expression does not match are hashed
+// whole.
+func RolloutByKey(percent float64, keyPattern string) (*RolloutCondition, error) {
+	key, err := regexp.Compile(keyPattern)
+	if err != nil {
+		return nil, ErrInvalidPattern
+	}
+	c := Rollout(percent)
+	c.key = key
+	return c, nil
+}
+
+// WithSalt mixes a salt into the hash, so that independent rollouts pick
+// independent sets of paths
+func (c *RolloutCondition) WithSalt(salt string) *RolloutCondition {
+	c.salt = salt
+	return c
+}
+
+// SetPercent changes the percentage of paths the condition matches, clamped
+// to between 0 and 100. It is safe to call while the condition is evaluated.
+func (c *RolloutCondition) SetPercent(percent float64) {
+	buckets := int64(math.Round(percent * rolloutBuckets / 100))
+	c.buckets.Store(min(max(buckets, 0), rolloutBuckets))
+}
+
+// Percent returns the percentage of paths the condition matches
+func (c *RolloutCondition) Percent() float64 {
+	return float64(c.buckets.Load()) * 100 / rolloutBuckets
+}
+
+// Evaluate reports whether a path's bucket is within the rollout
+func (c *RolloutCondition) Evaluate(p string, info os.FileInfo) bool {
+	return int64(shardScore(c.salt, c.keyFor(p))%rolloutBuckets) < c.buckets.Load()
+}
+
+// keyFor extracts the rollout key from a path: the first capture group of
+// the key pattern if it matches, otherwise the whole path
+func (c *RolloutCondition) keyFor(p string) string {
+	p = path.Clean("/" + p)
+	if c.key == nil {
+		return p
+	}
+	m := c.key.FindStringSubmatch(p)
+	if len(m) < 2 {
+		return p
+	}
+	return m[1]
+}
+
+// String returns the constructor call creating the condition
+func (c *RolloutCondition) String() string {
+	s := fmt.Sprintf("Rollout(%g)", c.Percent())
+	if c.key != nil {
+		s = fmt.Sprintf("RolloutByKey(%g, %q)", c.Percent(), c.key.String())
+	}
+	if c.salt != "" {
+		s += fmt.Sprintf(".WithSalt(%q)", c.salt)
+	}
+	return s
+}
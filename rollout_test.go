@@ -0,0 +1,160 @@
+package switchfs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/absfs/memfs"
+)
+
+func TestRolloutCondition(t *testing.T) {
+	const n = 20000
+	paths := make([]string, n)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("/data/file-%d.bin", i)
+	}
+
+	// matching counts the paths a condition matches
+	matching := func(c RouteCondition) map[string]bool {
+		matched := make(map[string]bool)
+		for _, p := range paths {
+			if c.Evaluate(p, nil) {
+				matched[p] = true
+			}
+		}
+		return matched
+	}
+
+	c := Rollout(10)
+	ten := matching(c)
+	if got := float64(len(ten)) / n * 100; got < 9 || got > 11 {
+		t.Errorf("Rollout(10) matched %.2f%% of paths", got)
+	}
+
+	// Raising the percentage keeps every path already rolled out
+	c.SetPercent(50)
+	fifty := matching(c)
+	for p := range ten {
+		if !fifty[p] {
+			t.Fatalf("%s left the rollout when it grew", p)
+		}
+	}
+	if got := float64(len(fifty)) / n * 100; got < 48 || got > 52 {
+		t.Errorf("Rollout(50) matched %.2f%% of paths", got)
+	}
+
+	c.SetPercent(0)
+	if len(matching(c)) != 0 {
+		t.Error("Rollout(0) matched paths")
+	}
+	c.SetPercent(150)
+	if len(matching(c)) != n || c.Percent() != 100 {
+		t.Errorf("Rollout(150) = %v%%, matched %d paths", c.Percent(), len(matching(c)))
+	}
+
+	t.Run("salt", func(t *testing.T) {
+		salted := matching(Rollout(10).WithSalt("migration-2"))
+		same := 0
+		for p := range salted {
+			if ten[p] {
+				same++
+			}
+		}
+		if same > len(salted)/2 {
+			t.Errorf("salted rollout shares %d of %d paths", same, len(salted))
+		}
+	})
+
+	t.Run("by key", func(t *testing.T) {
+		c, err := RolloutByKey(50, `^/users/([^/]+)/`)
+		if err != nil {
+			t.Fatalf("RolloutByKey() error = %v", err)
+		}
+		for i := 0; i < 100; i++ {
+			user := fmt.Sprintf("/users/u%d/", i)
+			want := c.Evaluate(user+"a.txt", nil)
+			if c.Evaluate(user+"photos/b.jpg", nil) != want {
+				t.Fatalf("paths of %s split across the rollout", user)
+			}
+		}
+		if _, err := RolloutByKey(50, "("); err != ErrInvalidPattern {
+			t.Errorf("RolloutByKey() error = %v, want ErrInvalidPattern", err)
+		}
+		if got := c.String(); got != `RolloutByKey(50, "^/users/([^/]+)/")` {
+			t.Errorf("String() = %q", got)
+		}
+	})
+}
+
+func TestRolloutRouting(t *testing.T) {
+	legacy, _ := memfs.NewFS()
+	next, _ := memfs.NewFS()
+	legacy.MkdirAll("/data", 0755)
+	next.MkdirAll("/data", 0755)
+
+	canary := Rollout(0)
+	fs, err := New(
+		WithRoute("/data", next, WithCondition(canary), WithPriority(10)),
+		WithRoute("/data", legacy),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	writeTestFile(t, fs, "/data/a.txt", "a")
+	if _, err := legacy.Stat("/data/a.txt"); err != nil {
+		t.Errorf("file not on legacy backend at 0%%: %v", err)
+	}
+
+	canary.SetPercent(100)
+	writeTestFile(t, fs, "/data/b.txt", "b")
+	if _, err := next.Stat("/data/b.txt"); err != nil {
+		t.Errorf("file not on new backend at 100%%: %v", err)
+	}
+}
+
+func TestRolloutNestedPaths(t *testing.T) {
+	legacy, _ := memfs.NewFS()
+	next, _ := memfs.NewFS()
+
+	canary := Rollout(50)
+	fs, err := New(
+		WithRoute("/data", next, WithCondition(canary), WithPriority(10)),
+		WithRoute("/data", legacy),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// Files of one directory hash into both buckets, so the directory is
+	// made on both backends
+	if err := fs.MkdirAll("/data/dir", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		writeTestFile(t, fs, fmt.Sprintf("/data/dir/file%d.txt", i), "data")
+	}
+
+	legacyEntries, _ := legacy.ReadDir("/data/dir")
+	nextEntries, _ := next.ReadDir("/data/dir")
+	if len(legacyEntries) == 0 || len(nextEntries) == 0 {
+		t.Errorf("files split %d/%d, want both backends used", len(legacyEntries), len(nextEntries))
+	}
+
+	entries, err := fs.ReadDir("/data/dir")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 20 {
+		t.Errorf("ReadDir() = %d entries, want 20", len(entries))
+	}
+
+	if err := fs.RemoveAll("/data/dir"); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	for name, backend := range map[string]*memfs.FileSystem{"legacy": legacy, "next": next} {
+		if _, err := backend.Stat("/data/dir"); err == nil {
+			t.Errorf("directory left on the %s backend", name)
+		}
+	}
+}
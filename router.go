@@ -26,6 +26,18 @@ type Router interface {
 	Routes() []Route
 }
 
+// OperationRouter is implemented by routers that choose the route serving
+// Routers without it serve every operation with RouteWithInfo or Route.
+// SwitchFS matches the patterns of Routes itself for routers without it.
+type OperationRouter interface {
+	// RouteOperation finds the route serving an operation on a given path
+	RouteOperation(op Operation, path string) (*Route, error)
+
+	// RoutesFor returns every route serving an operation on a given path,
+	// in priority order
+	RoutesFor(op Operation, path string) []*Route
+}
+
 // router is the default implementation of Router
 type router struct {
 	mu     sync.RWMutex
@@ -41,23 +53,27 @@ func NewRouter() Router {
 
 // AddRoute adds a routing rule
 func (r *router) AddRoute(route Route) error {
-	if route.Backend == nil {
+	if route.Backend == nil && len(route.Shards) == 0 && len(route.Targets) == 0 {
 		return ErrNilBackend
 	}
 
-	// Compile the pattern matcher
-	matcher, err := compileMatcher(route.Pattern, route.Type)
-	if err != nil {
-		return err
+	// Build the matcher and derived state of routes added directly
+	if !route.built {
+		if err := buildRoute(&route); err != nil {
+			return err
+		}
 	}
-	route.compiled = matcher
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	// Check for duplicate patterns
+	// Check for duplicate patterns; routes on the same pattern may coexist
+	// when they handle disjoint operations or when dispatch can tell them
+	// apart
 	for _, existing := range r.routes {
-		if existing.Pattern == route.Pattern && existing.Type == route.Type {
+		if existing.Pattern == route.Pattern && existing.Type == route.Type &&
+			existing.operations()&route.operations() != 0 &&
+			!existing.distinguishable() && !route.distinguishable() {
 			return ErrDuplicateRoute
 		}
 	}
@@ -97,6 +113,12 @@ func (r *router) Route(path string) (absfs.FileSystem, error) {
 	// Iterate through routes in priority order
 	for _, route := range r.routes {
 		if route.compiled != nil && route.compiled.Match(path) {
+			if route.sharded != nil {
+				return route.sharded.backendFor(path), nil
+			}
+			if route.balanced != nil {
+				return route.balanced, nil
+			}
 			return route.Backend, nil
 		}
 	}
@@ -104,6 +126,42 @@ func (r *router) Route(path string) (absfs.FileSystem, error) {
 	return nil, ErrNoRoute
 }
 
+// RouteOperation finds the route serving an operation on a given path
+func (r *router) RouteOperation(op Operation, path string) (*Route, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Iterate through routes in priority order, skipping routes scoped to
+	// other operations
+	for i := range r.routes {
+		route := &r.routes[i]
+		if !route.handles(op) {
+			continue
+		}
+		if route.compiled != nil && route.compiled.Match(path) {
+			return route, nil
+		}
+	}
+
+	return nil, ErrNoRoute
+}
+
+// RoutesFor returns every route serving an operation on a given path, in
+// priority order
+func (r *router) RoutesFor(op Operation, path string) []*Route {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var routes []*Route
+	for i := range r.routes {
+		route := &r.routes[i]
+		if route.handles(op) && route.compiled != nil && route.compiled.Match(path) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
 // RouteWithInfo finds the route for a given path with file info for condition evaluation
 func (r *router) RouteWithInfo(path string, info os.FileInfo) (*Route, error) {
 	r.mu.RLock()
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/absfs/absfs"
+	"github.com/absfs/memfs"
 )
 
 // mockFS is a simple mock filesystem for testing
@@ -347,3 +348,80 @@ func TestRouter_DuplicateRoute(t *testing.T) {
 		t.Errorf("AddRoute() should return ErrDuplicateRoute, got %v", err)
 	}
 }
+
+func TestRouter_AddRouteBuildsState(t *testing.T) {
+	t.Run("shards", func(t *testing.T) {
+		shards := newShards(t, "a", "b", "c")
+		fs, err := New()
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if err := fs.Router().AddRoute(Route{Pattern: "/users", Shards: shards}); err != nil {
+			t.Fatalf("AddRoute() error = %v", err)
+		}
+
+		if err := fs.MkdirAll("/users/alice", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, fs, "/users/alice/a.txt", "sharded")
+		if _, err := fs.Stat("/users/alice/a.txt"); err != nil {
+			t.Errorf("Stat() error = %v", err)
+		}
+		want := ShardFor("alice", shards)
+		for _, shard := range shards {
+			if shard.Name != want {
+				continue
+			}
+			if _, err := shard.Backend.Stat("/users/alice/a.txt"); err != nil {
+				t.Errorf("file not on shard %s: %v", want, err)
+			}
+		}
+	})
+
+	t.Run("targets", func(t *testing.T) {
+		a, _ := memfs.NewFS()
+		b, _ := memfs.NewFS()
+		fs, err := New()
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		route := Route{Pattern: "/data", Targets: []Target{{Backend: a}, {Backend: b}}}
+		if err := fs.Router().AddRoute(route); err != nil {
+			t.Fatalf("AddRoute() error = %v", err)
+		}
+
+		if err := fs.MkdirAll("/data", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, fs, "/data/file.txt", "balanced")
+		if _, err := fs.Stat("/data/file.txt"); err != nil {
+			t.Errorf("Stat() error = %v", err)
+		}
+		for i, target := range []*memfs.FileSystem{a, b} {
+			if _, err := target.Stat("/data/file.txt"); err != nil {
+				t.Errorf("file not on target %d: %v", i, err)
+			}
+		}
+	})
+
+	t.Run("mirrors", func(t *testing.T) {
+		primary, _ := memfs.NewFS()
+		mirror, _ := memfs.NewFS()
+		fs, err := New()
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		route := Route{Pattern: "/data", Backend: primary, Mirrors: []absfs.FileSystem{mirror}}
+		if err := fs.Router().AddRoute(route); err != nil {
+			t.Fatalf("AddRoute() error = %v", err)
+		}
+
+		if err := fs.MkdirAll("/data", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, fs, "/data/file.txt", "mirrored")
+		if _, err := mirror.Stat("/data/file.txt"); err != nil {
+			t.Errorf("file not on the mirror: %v", err)
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package switchfs
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleCondition matches during recurring time windows, read from a clock
+// when the condition is evaluated. It is a DispatchCondition, so SwitchFS
+// evaluates it on every call.
+type ScheduleCondition struct {
+	// Location is the time zone the schedule is read in. Nil reads the
+	// clock's time in its own location.
+	Location *time.Location
+
+	// Clock provides the current time. Nil means SystemClock.
+	Clock Clock
+
+	expr     string
+	kind     string
+	schedule schedule
+}
+
+// schedule reports whether a time falls in a schedule
+type schedule interface {
+	match(t time.Time) bool
+}
+
+// Evaluate reports whether the current time falls in the schedule
+func (c *ScheduleCondition) Evaluate(path string, info os.FileInfo) bool {
+	clock := c.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+	now := clock.Now()
+	if c.Location != nil {
+		now = now.In(c.Location)
+	}
+	return c.schedule.match(now)
+}
+
+// String returns the constructor call creating the condition
+func (c *ScheduleCondition) String() string {
+	s := fmt.Sprintf("%s(%q)", c.kind, c.expr)
+	if c.Location != nil {
+		s += fmt.Sprintf(".In(%q)", c.Location.String())
+	}
+	return s
+}
+
+// WithClock sets the clock the schedule is read from
+func (c *ScheduleCondition) WithClock(clock Clock) *ScheduleCondition {
+	c.Clock = clock
+	return c
+}
+
+// In sets the time zone the schedule is read in
+func (c *ScheduleCondition) In(loc *time.Location) *ScheduleCondition {
+	c.Location = loc
+	return c
+}
+
+// Window creates a condition that matches during a weekly time window, such
+// as "Mon-Fri 09:00-17:00", "Sat,Sun" or "22:00-06:00". Days are three-letter
+// English names, alone, in lists or in ranges. A time range that ends before
+// it starts runs past midnight into the next day, and belongs to the day it
+// starts on. Leaving out the days means every day; leaving out the time
+// range means whole days.
+func Window(expr string) (*ScheduleCondition, error) {
+	w := &windowSchedule{}
+	dayPart, timePart := "", ""
+	for _, field := range strings.Fields(expr) {
+		switch {
+		case strings.Contains(field, ":") && timePart == "":
+			timePart = field
+		case !strings.Contains(field, ":") && dayPart == "":
+			dayPart = field
+		default:
+			return nil, ErrInvalidSchedule
+		}
+	}
+	if dayPart == "" && timePart == "" {
+		return nil, ErrInvalidSchedule
+	}
+
+	if dayPart == "" {
+		w.days = allDays
+	} else {
+		days, err := parseField(dayPart, 0, 6, dayNames)
+		if err != nil {
+			return nil, err
+		}
+		w.days = days
+	}
+
+	if timePart != "" {
+		from, to, ok := strings.Cut(timePart, "-")
+		if !ok {
+			return nil, ErrInvalidSchedule
+		}
+		var err error
+		if w.start, err = parseClockTime(from); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseClockTime(to); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ScheduleCondition{expr: expr, kind: "Window", schedule: w}, nil
+}
+
+// HourRange creates a condition that matches every day from hour from up to
+// hour to, running past midnight when to is less than from
+func HourRange(from, to int) *ScheduleCondition {
+	from, to = ((from%24)+24)%24, ((to%24)+24)%24
+	return &ScheduleCondition{
+		expr:     fmt.Sprintf("%02d:00-%02d:00", from, to),
+		kind:     "Window",
+		schedule: &windowSchedule{days: allDays, start: from * 60, end: to * 60},
+	}
+}
+
+// OnDays creates a condition that matches on the given days of the week
+func OnDays(days ...time.Weekday) *ScheduleCondition {
+	var set uint64
+	names := make([]string, len(days))
+	for i, day := range days {
+		set |= 1 << (uint(day) % 7)
+		names[i] = day.String()[:3]
+	}
+	return &ScheduleCondition{
+		expr:     strings.Join(names, ","),
+		kind:     "Window",
+		schedule: &windowSchedule{days: set},
+	}
+}
+
+// Cron creates a condition that matches during every minute a five-field
+// cron expression ("minute hour day-of-month month day-of-week") selects,
+// so "* 22-23,0-5 * * *" matches overnight. Fields accept "*", numbers,
+// lists, ranges and steps; months and days of the week also accept
+// three-letter English names, and Sunday is 0 or 7. As in cron, a day
+// matches when either day field does if both are restricted.
+func Cron(expr string) (*ScheduleCondition, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, ErrInvalidSchedule
+	}
+
+	c := &cronSchedule{}
+	var err error
+	if c.minutes, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if c.hours, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if c.monthDays, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if c.months, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, err
+	}
+	if c.weekDays, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, err
+	}
+	// Both 0 and 7 stand for Sunday
+	if c.weekDays&(1<<7) != 0 {
+		c.weekDays = c.weekDays&^(1<<7) | 1
+	}
+	// As in cron, a day field starting with "*", such as "*/2", counts as
+	// unrestricted, so that both day fields must match
+	c.anyMonthDay = strings.HasPrefix(fields[2], "*")
+	c.anyWeekDay = strings.HasPrefix(fields[4], "*")
+
+	return &ScheduleCondition{expr: expr, kind: "Cron", schedule: c}, nil
+}
+
+// windowSchedule matches days of the week between two times of day, in
+// minutes since midnight. Equal times cover whole days.
+type windowSchedule struct {
+	days       uint64
+	start, end int
+}
+
+func (w *windowSchedule) match(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	today := w.days&(1<<uint(t.Weekday())) != 0
+
+	switch {
+	case w.start == w.end:
+		return today
+	case w.start < w.end:
+		return today && minute >= w.start && minute < w.end
+	default:
+		// The window started yesterday and runs past midnight
+		yesterday := w.days&(1<<uint((t.Weekday()+6)%7)) != 0
+		return today && minute >= w.start || yesterday && minute < w.end
+	}
+}
+
+// cronSchedule matches the minutes a cron expression selects
+type cronSchedule struct {
+	minutes, hours, monthDays, months, weekDays uint64
+	anyMonthDay, anyWeekDay                     bool
+}
+
+func (c *cronSchedule) match(t time.Time) bool {
+	if c.minutes&(1<<uint(t.Minute())) == 0 || c.hours&(1<<uint(t.Hour())) == 0 ||
+		c.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	monthDay := c.monthDays&(1<<uint(t.Day())) != 0
+	weekDay := c.weekDays&(1<<uint(t.Weekday())) != 0
+	if c.anyMonthDay || c.anyWeekDay {
+		return monthDay && weekDay
+	}
+	return monthDay || weekDay
+}
+
+// allDays is the set of every day of the week
+const allDays = 1<<7 - 1
+
+var (
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+	monthNames = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+)
+
+// parseField parses a comma-separated list of values, ranges and steps
+// between first and last into a bit set. Ranges of named values may wrap,
+// as in "fri-mon".
+func parseField(field string, first, last int, names []string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n <= 0 {
+				return 0, ErrInvalidSchedule
+			}
+			step = n
+		}
+
+		lo, hi := first, last
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(from, first, last, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = parseValue(to, first, last, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = last
+			}
+		}
+
+		if hi < lo {
+			if names == nil {
+				return 0, ErrInvalidSchedule
+			}
+			hi += last - first + 1
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(first+(v-first)%(last-first+1))
+		}
+	}
+	return set, nil
+}
+
+// parseValue parses a number between first and last, or one of names
+func parseValue(s string, first, last int, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < first || n > last {
+		return 0, ErrInvalidSchedule
+	}
+	return n, nil
+}
+
+// parseClockTime parses a time of day such as "22:30" into minutes since
+// midnight. "24:00" stands for midnight at the end of the day.
+func parseClockTime(s string) (int, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, ErrInvalidSchedule
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 24 {
+		return 0, ErrInvalidSchedule
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 || h == 24 && m != 0 {
+		return 0, ErrInvalidSchedule
+	}
+	return (h*60 + m) % (24 * 60), nil
+}
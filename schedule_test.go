@@ -0,0 +1,195 @@
+package switchfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/absfs/memfs"
+)
+
+func TestScheduleConditions(t *testing.T) {
+	// at returns a clock stopped at a UTC time in the week of Monday
+	// 2024-06-03
+	at := func(day time.Weekday, hour, minute int) Clock {
+		now := time.Date(2024, 6, 2+int(day), hour, minute, 0, 0, time.UTC)
+		return ClockFunc(func() time.Time { return now })
+	}
+
+	mustWindow := func(expr string) *ScheduleCondition {
+		c, err := Window(expr)
+		if err != nil {
+			t.Fatalf("Window(%q) error = %v", expr, err)
+		}
+		return c
+	}
+	mustCron := func(expr string) *ScheduleCondition {
+		c, err := Cron(expr)
+		if err != nil {
+			t.Fatalf("Cron(%q) error = %v", expr, err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name  string
+		cond  *ScheduleCondition
+		clock Clock
+		want  bool
+	}{
+		{"business hours", mustWindow("Mon-Fri 09:00-17:00"), at(time.Wednesday, 10, 30), true},
+		{"business hours, evening", mustWindow("Mon-Fri 09:00-17:00"), at(time.Wednesday, 17, 0), false},
+		{"business hours, weekend", mustWindow("Mon-Fri 09:00-17:00"), at(time.Saturday, 10, 0), false},
+		{"weekend", mustWindow("Sat,Sun"), at(time.Sunday, 23, 59), true},
+		{"wrapping days", mustWindow("fri-mon"), at(time.Monday, 12, 0), true},
+		{"wrapping days, midweek", mustWindow("fri-mon"), at(time.Wednesday, 12, 0), false},
+		{"overnight, before midnight", mustWindow("Mon-Fri 22:00-06:00"), at(time.Friday, 23, 0), true},
+		{"overnight, after midnight", mustWindow("Mon-Fri 22:00-06:00"), at(time.Saturday, 5, 59), true},
+		{"overnight, not started", mustWindow("Mon-Fri 22:00-06:00"), at(time.Monday, 3, 0), false},
+		{"until midnight", mustWindow("18:00-24:00"), at(time.Tuesday, 23, 59), true},
+		{"hour range", HourRange(22, 6), at(time.Tuesday, 2, 0), true},
+		{"hour range, daytime", HourRange(22, 6), at(time.Tuesday, 12, 0), false},
+		{"on days", OnDays(time.Saturday, time.Sunday), at(time.Saturday, 0, 0), true},
+		{"cron overnight", mustCron("* 22-23,0-5 * * *"), at(time.Thursday, 4, 10), true},
+		{"cron overnight, morning", mustCron("* 22-23,0-5 * * *"), at(time.Thursday, 6, 0), false},
+		{"cron step", mustCron("*/15 * * * *"), at(time.Thursday, 4, 30), true},
+		{"cron step, off", mustCron("*/15 * * * *"), at(time.Thursday, 4, 31), false},
+		{"cron names", mustCron("* * * jun sat,sun"), at(time.Sunday, 9, 0), true},
+		{"cron sunday as 7", mustCron("* * * * 7"), at(time.Sunday, 9, 0), true},
+		{"cron either day field", mustCron("* * 1 * mon"), at(time.Monday, 9, 0), true},
+		{"cron neither day field", mustCron("* * 1 * mon"), at(time.Tuesday, 9, 0), false},
+		{"cron weekday range to 7", mustCron("* * * * 0-7"), at(time.Wednesday, 9, 0), true},
+		{"cron weekday range ending sunday", mustCron("* * * * fri-7"), at(time.Sunday, 9, 0), true},
+		{"cron weekday step", mustCron("* * * * */7"), at(time.Sunday, 9, 0), true},
+		{"cron stepped day field", mustCron("* * */2 * mon"), at(time.Monday, 9, 0), true},
+		{"cron stepped day field, both must match", mustCron("* * */2 * mon"), at(time.Wednesday, 9, 0), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cond.WithClock(tt.clock).Evaluate("/x", nil); got != tt.want {
+				t.Errorf("%v.Evaluate() = %v, want %v", tt.cond, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("time zone", func(t *testing.T) {
+		tokyo := time.FixedZone("JST", 9*60*60)
+		// 15:00 UTC Tuesday is 00:00 Wednesday in Tokyo
+		cond := mustWindow("Wed 00:00-01:00").WithClock(at(time.Tuesday, 15, 0))
+		if cond.Evaluate("/x", nil) {
+			t.Error("window matched in UTC")
+		}
+		if !cond.In(tokyo).Evaluate("/x", nil) {
+			t.Error("window did not match in Tokyo")
+		}
+		if got := cond.String(); got != `Window("Wed 00:00-01:00").In("JST")` {
+			t.Errorf("String() = %q", got)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, expr := range []string{"", "Mon-Fri 9-17", "Moon", "Mon 25:00-26:00", "Mon Tue"} {
+			if _, err := Window(expr); err != ErrInvalidSchedule {
+				t.Errorf("Window(%q) error = %v, want ErrInvalidSchedule", expr, err)
+			}
+		}
+		for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *"} {
+			if _, err := Cron(expr); err != ErrInvalidSchedule {
+				t.Errorf("Cron(%q) error = %v, want ErrInvalidSchedule", expr, err)
+			}
+		}
+	})
+}
+
+func TestScheduledRouting(t *testing.T) {
+	cheap, _ := memfs.NewFS()
+	primary, _ := memfs.NewFS()
+	cheap.MkdirAll("/batch", 0755)
+	primary.MkdirAll("/batch", 0755)
+
+	now := time.Date(2024, 6, 3, 23, 0, 0, 0, time.UTC)
+	overnight := HourRange(22, 6).WithClock(ClockFunc(func() time.Time { return now }))
+
+	fs, err := New(
+		WithRoute("/batch", cheap, WithCondition(overnight), WithOperations(OpCreate, OpWrite), WithPriority(10)),
+		WithRoute("/batch", primary),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	writeTestFile(t, fs, "/batch/night.csv", "night")
+	now = now.Add(10 * time.Hour)
+	writeTestFile(t, fs, "/batch/day.csv", "day")
+
+	if _, err := cheap.Stat("/batch/night.csv"); err != nil {
+		t.Errorf("overnight write not on cheap backend: %v", err)
+	}
+	if _, err := primary.Stat("/batch/day.csv"); err != nil {
+		t.Errorf("daytime write not on primary backend: %v", err)
+	}
+}
+
+// TestScheduledRoutingWithProbing runs the scheduled routes example of the
+// README end to end
+func TestScheduledRoutingWithProbing(t *testing.T) {
+	cheap, _ := memfs.NewFS()
+	primary, _ := memfs.NewFS()
+
+	now := time.Date(2024, 6, 3, 23, 0, 0, 0, time.UTC) // A Monday night
+	overnight, err := Window("Mon-Fri 22:00-06:00")
+	if err != nil {
+		t.Fatalf("Window() error = %v", err)
+	}
+	overnight = overnight.WithClock(ClockFunc(func() time.Time { return now }))
+
+	fs, err := New(
+		WithRoute("/batch", cheap, WithCondition(overnight), WithPriority(10)),
+		WithRoute("/batch", primary),
+		WithProbing(time.Minute, OpAll),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := fs.MkdirAll("/batch", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	writeTestFile(t, fs, "/batch/night.csv", "night")
+	now = now.Add(10 * time.Hour)
+	writeTestFile(t, fs, "/batch/day.csv", "day")
+
+	if _, err := cheap.Stat("/batch/night.csv"); err != nil {
+		t.Errorf("overnight write not on cheap backend: %v", err)
+	}
+	if _, err := primary.Stat("/batch/day.csv"); err != nil {
+		t.Errorf("daytime write not on primary backend: %v", err)
+	}
+
+	// Files written overnight are read and updated where they are by day
+	if data, err := fs.ReadFile("/batch/night.csv"); err != nil || string(data) != "night" {
+		t.Errorf("ReadFile() = %q, %v, want the overnight file", data, err)
+	}
+	writeTestFile(t, fs, "/batch/night.csv", "updated")
+	if data, err := cheap.ReadFile("/batch/night.csv"); err != nil || string(data) != "updated" {
+		t.Errorf("cheap backend = %q, %v, want the file updated in place", data, err)
+	}
+	if _, err := primary.Stat("/batch/night.csv"); err == nil {
+		t.Error("update copied the overnight file to the primary backend")
+	}
+
+	entries, err := fs.ReadDir("/batch")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("ReadDir() = %d entries, want both files", len(entries))
+	}
+
+	if err := fs.Remove("/batch/night.csv"); err != nil {
+		t.Errorf("Remove() error = %v", err)
+	}
+	if _, err := cheap.Stat("/batch/night.csv"); err == nil {
+		t.Error("Remove() left the overnight file")
+	}
+}
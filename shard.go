@@ -0,0 +1,150 @@
+package switchfs
+
+import (
+	"hash/fnv"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/absfs/absfs"
+)
+
+// Shard is a named backend in a sharded route. Placement depends only on
+// shard names, so shards keep their keys when other shards are added or
+// removed.
+type Shard struct {
+	// Name identifies the shard for placement
+	Name string
+
+	// Backend is the shard's filesystem
+	Backend absfs.FileSystem
+}
+
+// ShardMove describes a key whose shard changes between two shard sets
+type ShardMove struct {
+	Key  string
+	From string
+	To   string
+}
+
+// shardSet picks a shard for each path by rendezvous hashing of its key
+type shardSet struct {
+	shards []Shard
+	key    *regexp.Regexp
+
+	// root is the prefix of a prefix route, below which the first path
+	// segment is the default key
+	root string
+}
+
+// newShardSet builds the shard set for a sharded route
+func newShardSet(route *Route) (*shardSet, error) {
+	seen := make(map[string]bool, len(route.Shards))
+	for _, shard := range route.Shards {
+		if shard.Backend == nil {
+			return nil, ErrNilBackend
+		}
+		if seen[shard.Name] {
+			return nil, ErrDuplicateShard
+		}
+		seen[shard.Name] = true
+	}
+
+	set := &shardSet{shards: route.Shards}
+	if route.Type == PatternPrefix && path.IsAbs(route.Pattern) {
+		set.root = path.Clean(route.Pattern)
+	} else if route.ShardKey == "" {
+		return nil, ErrShardKeyRequired
+	}
+	if route.ShardKey != "" {
+		key, err := regexp.Compile(route.ShardKey)
+		if err != nil {
+			return nil, ErrInvalidPattern
+		}
+		set.key = key
+	}
+	return set, nil
+}
+
+// keyFor extracts the shard key from a path: the first capture group of the
+// shard key pattern if it matches, otherwise the first path segment below the
+// route's prefix, so that a directory and its children share a shard
+func (s *shardSet) keyFor(name string) string {
+	if s.key != nil {
+		if m := s.key.FindStringSubmatch(name); len(m) >= 2 {
+			return m[1]
+		}
+	}
+	if s.root == "" {
+		return name
+	}
+	rel := strings.TrimPrefix(path.Clean(name), s.root)
+	segment, _, _ := strings.Cut(strings.TrimPrefix(rel, "/"), "/")
+	return segment
+}
+
+// spans reports whether a path lies above the keyed trees of the shard set,
+// such as the route's root, so that directories there belong on every shard
+func (s *shardSet) spans(name string) bool {
+	if s.key != nil && s.key.MatchString(name) {
+		return false
+	}
+	return s.root != "" && s.keyFor(name) == ""
+}
+
+// backendFor returns the shard owning a path
+func (s *shardSet) backendFor(path string) absfs.FileSystem {
+	return pickShard(s.shards, s.keyFor(path)).Backend
+}
+
+// pickShard returns the shard with the highest score for key
+func pickShard(shards []Shard, key string) Shard {
+	var best Shard
+	var bestScore uint64
+	for i, shard := range shards {
+		score := shardScore(shard.Name, key)
+		if i == 0 || score > bestScore {
+			best, bestScore = shard, score
+		}
+	}
+	return best
+}
+
+// shardScore hashes a shard name and key together
+func shardScore(name, key string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+
+	// Finalize with splitmix64 so similar inputs spread evenly
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// ShardFor returns the name of the shard a key is placed on
+func ShardFor(key string, shards []Shard) string {
+	if len(shards) == 0 {
+		return ""
+	}
+	return pickShard(shards, key).Name
+}
+
+// PlanReshard lists the keys whose shard changes when moving from one shard
+// set to another
+func PlanReshard(keys []string, from, to []Shard) []ShardMove {
+	var moves []ShardMove
+	for _, key := range keys {
+		oldShard := ShardFor(key, from)
+		newShard := ShardFor(key, to)
+		if oldShard != newShard {
+			moves = append(moves, ShardMove{Key: key, From: oldShard, To: newShard})
+		}
+	}
+	return moves
+}
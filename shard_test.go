@@ -0,0 +1,199 @@
+package switchfs
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/absfs/memfs"
+)
+
+// newShards creates named memfs shards
+func newShards(t *testing.T, names ...string) []Shard {
+	t.Helper()
+
+	shards := make([]Shard, len(names))
+	for i, name := range names {
+		mem, err := memfs.NewFS()
+		if err != nil {
+			t.Fatalf("NewFS() error = %v", err)
+		}
+		shards[i] = Shard{Name: name, Backend: mem}
+	}
+	return shards
+}
+
+func TestShardedRoute(t *testing.T) {
+	shards := newShards(t, "a", "b", "c")
+
+	fs, err := New(WithShardedRoute("/users", shards, WithShardKey(`^/users/([^/]+)`)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	t.Run("paths spread across shards", func(t *testing.T) {
+		counts := make(map[string]int)
+		for i := 0; i < 300; i++ {
+			user := fmt.Sprintf("user%d", i)
+			name := fmt.Sprintf("/users/%s/profile.json", user)
+			if err := fs.MkdirAll("/users/"+user, 0755); err != nil {
+				t.Fatalf("MkdirAll() error = %v", err)
+			}
+			f, err := fs.Create(name)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			f.Close()
+
+			want := ShardFor(user, shards)
+			for _, shard := range shards {
+				_, err := shard.Backend.Stat(name)
+				if (err == nil) != (shard.Name == want) {
+					t.Fatalf("%s on shard %s = %v, want only on shard %s", name, shard.Name, err == nil, want)
+				}
+			}
+			counts[want]++
+		}
+
+		for _, shard := range shards {
+			if counts[shard.Name] < 50 {
+				t.Errorf("shard %s holds %d of 300 paths, want an even spread", shard.Name, counts[shard.Name])
+			}
+		}
+	})
+
+	t.Run("captured segment keeps a user on one shard", func(t *testing.T) {
+		b1, err := fs.getBackend("/users/alice/a.txt")
+		if err != nil {
+			t.Fatalf("getBackend() error = %v", err)
+		}
+		b2, err := fs.getBackend("/users/alice/photos/b.jpg")
+		if err != nil {
+			t.Fatalf("getBackend() error = %v", err)
+		}
+		if b1 != b2 {
+			t.Error("paths of the same user routed to different shards")
+		}
+	})
+}
+
+func TestShardedRoute_DefaultKey(t *testing.T) {
+	shards := newShards(t, "a", "b", "c")
+
+	fs, err := New(WithShardedRoute("/users", shards))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 30; i++ {
+		dir := fmt.Sprintf("/users/user%d", i)
+		if err := fs.MkdirAll(dir+"/photos", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, fs, dir+"/photos/a.jpg", "photo")
+
+		want := ShardFor(fmt.Sprintf("user%d", i), shards)
+		for _, shard := range shards {
+			if shard.Name != want {
+				continue
+			}
+			if _, err := shard.Backend.Stat(dir + "/photos/a.jpg"); err != nil {
+				t.Errorf("%s not on the shard of its top directory: %v", dir, err)
+			}
+		}
+	}
+}
+
+func TestShardedRoute_Root(t *testing.T) {
+	shards := newShards(t, "a", "b", "c")
+
+	fs, err := New(WithShardedRoute("/users", shards))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// The root holds no shard key, so it is made on every shard
+	if err := fs.MkdirAll("/users", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	for _, shard := range shards {
+		if _, err := shard.Backend.Stat("/users"); err != nil {
+			t.Errorf("root missing on shard %s: %v", shard.Name, err)
+		}
+	}
+
+	for i := 0; i < 12; i++ {
+		dir := fmt.Sprintf("/users/user%d", i)
+		if err := fs.Mkdir(dir, 0755); err != nil {
+			t.Fatalf("Mkdir(%s) error = %v", dir, err)
+		}
+		writeTestFile(t, fs, dir+"/profile.json", "{}")
+	}
+
+	entries, err := fs.ReadDir("/users")
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 12 {
+		t.Errorf("ReadDir() = %d entries, want 12 merged from every shard", len(entries))
+	}
+
+	if err := fs.RemoveAll("/users"); err != nil {
+		t.Fatalf("RemoveAll() error = %v", err)
+	}
+	for _, shard := range shards {
+		if _, err := shard.Backend.Stat("/users"); err == nil {
+			t.Errorf("root left on shard %s", shard.Name)
+		}
+	}
+}
+
+func TestPlanReshard(t *testing.T) {
+	before := []Shard{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	after := append(append([]Shard(nil), before...), Shard{Name: "d"})
+
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	moves := PlanReshard(keys, before, after)
+	for _, m := range moves {
+		if m.To != "d" {
+			t.Errorf("key %s moved %s -> %s, want moves only onto the new shard", m.Key, m.From, m.To)
+		}
+		if m.From != ShardFor(m.Key, before) {
+			t.Errorf("key %s From = %s, want %s", m.Key, m.From, ShardFor(m.Key, before))
+		}
+	}
+	if len(moves) < 150 || len(moves) > 350 {
+		t.Errorf("%d of 1000 keys moved, want about a quarter", len(moves))
+	}
+
+	if moves := PlanReshard(keys, before, before); len(moves) != 0 {
+		t.Errorf("%d keys moved between identical shard sets", len(moves))
+	}
+}
+
+func TestShardedRoute_Errors(t *testing.T) {
+	mem, _ := memfs.NewFS()
+
+	tests := []struct {
+		name string
+		opt  Option
+		want error
+	}{
+		{"no shards", WithShardedRoute("/x", nil), ErrNilBackend},
+		{"nil shard backend", WithShardedRoute("/x", []Shard{{Name: "a"}}), ErrNilBackend},
+		{"duplicate shard name", WithShardedRoute("/x", []Shard{{Name: "a", Backend: mem}, {Name: "a", Backend: mem}}), ErrDuplicateShard},
+		{"invalid shard key", WithShardedRoute("/x", []Shard{{Name: "a", Backend: mem}}, WithShardKey("([")), ErrInvalidPattern},
+		{"glob without shard key", WithShardedRoute("/x/*", []Shard{{Name: "a", Backend: mem}}, WithPatternType(PatternGlob)), ErrShardKeyRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opt); err != tt.want {
+				t.Errorf("New() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,7 @@
 package switchfs
 
 import (
-	"io"
+	"context"
 	"io/fs"
 	"os"
 	"path"
@@ -16,6 +16,21 @@ type SwitchFS struct {
 	defaultFS  absfs.FileSystem
 	currentDir string
 	tempDir    string
+
+	// ctx bounds rate-limit waits of calls made through this SwitchFS
+	ctx context.Context
+
+	// status caches the health and capacity backends report to conditions
+	status *statusCache
+
+	// probes enables probing lookups and remembers paths they missed
+	probes *probeCache
+
+	// links caches the symbolic links found while resolving paths
+	links *linkCache
+
+	// pending holds the routes options add until New registers them
+	pending []Route
 }
 
 // Ensure SwitchFS implements absfs.FileSystem
@@ -27,6 +42,9 @@ func New(opts ...Option) (*SwitchFS, error) {
 		router:     NewRouter(),
 		currentDir: "/",
 		tempDir:    "/tmp",
+		ctx:        context.Background(),
+		status:     newStatusCache(defaultStatusInterval),
+		links:      newLinkCache(defaultLinkTTL),
 	}
 
 	for _, opt := range opts {
@@ -35,24 +53,282 @@ func New(opts ...Option) (*SwitchFS, error) {
 		}
 	}
 
+	// Routes are registered once every option is applied, so that they see
+	// the router and probing settings wherever those options appear
+	for _, route := range fs.pending {
+		if err := fs.addRoute(route); err != nil {
+			return nil, err
+		}
+	}
+	fs.pending = nil
+
 	// Note: separator fields removed in absfs 1.0
 	// All absfs filesystems use Unix-style '/' separator
 
 	return fs, nil
 }
 
-// getBackend finds the appropriate backend for a path
-func (fs *SwitchFS) getBackend(path string) (absfs.FileSystem, error) {
-	// Try to route the path
+// addRoute builds the state a route needs for dispatch and adds it to the
+// router. The state travels with the route, so custom routers keep it.
+func (fs *SwitchFS) addRoute(route Route) error {
+	if err := buildRoute(&route); err != nil {
+		return err
+	}
+	route.probed = fs.probes != nil
+	return fs.router.AddRoute(route)
+}
+
+// buildRoute compiles a route's pattern and builds its mirror set, shard
+// set, balancer, quota tracker, ID mapper and rate limiters
+func buildRoute(route *Route) error {
+	matcher, err := compileMatcher(route.Pattern, route.Type)
+	if err != nil {
+		return err
+	}
+	route.compiled = matcher
+
+	// Build the mirror set
+	if len(route.Mirrors) > 0 {
+		mirror, err := newMirrorFS(route)
+		if err != nil {
+			return err
+		}
+		route.mirror = mirror
+	}
+
+	// Build the shard set
+	if len(route.Shards) > 0 {
+		sharded, err := newShardSet(route)
+		if err != nil {
+			return err
+		}
+		route.sharded = sharded
+	}
+
+	// Build the balancer
+	if len(route.Targets) > 0 {
+		balanced, err := newBalancedFS(route)
+		if err != nil {
+			return err
+		}
+		route.balanced = balanced
+	}
+
+	// Build the quota tracker once the route's backends are known
+	if route.MaxBytes > 0 || route.MaxFiles > 0 {
+		route.quota = newQuotaTracker(route)
+	}
+
+	// Build the ID mapper
+	if len(route.UIDMap) > 0 || len(route.GIDMap) > 0 {
+		ids, err := newIDMapper(route)
+		if err != nil {
+			return err
+		}
+		route.ids = ids
+	}
+
+	// Note whether dispatch must sniff content, evaluate the call context
+	// or read file information for the route
+	route.sniffs = usesContent(route.Condition)
+	route.contextual = usesContext(route.Condition)
+	route.dispatched = usesDispatch(route.Condition)
+	route.inspects = inspectsFiles(route.Condition)
+
+	// Build the rate limiters
+	if route.OpsPerSecond > 0 || route.ReadBytesPerSecond > 0 || route.WriteBytesPerSecond > 0 {
+		route.limits = newRouteLimits(route)
+	}
+	route.built = true
+	return nil
+}
+
+// routeOperation finds the route serving an operation on a path. Routers
+// that do not choose routes per operation serve every operation with the
+// route from RouteWithInfo or, failing that, the backend from Route.
+func (fs *SwitchFS) routeOperation(op Operation, path string) (*Route, error) {
+	if r, ok := fs.router.(OperationRouter); ok {
+		return r.RouteOperation(op, path)
+	}
+	if route, err := fs.router.RouteWithInfo(path, nil); err == nil && route != nil {
+		return route, nil
+	}
 	backend, err := fs.router.Route(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Route{Pattern: path, Backend: backend}, nil
+}
+
+// routesFor returns every route serving an operation on a path, in priority
+// order
+func (fs *SwitchFS) routesFor(op Operation, path string) []*Route {
+	if r, ok := fs.router.(OperationRouter); ok {
+		return r.RoutesFor(op, path)
+	}
+	route, err := fs.routeOperation(op, path)
+	if err != nil {
+		return nil
+	}
+	return []*Route{route}
+}
+
+// getBackend finds the backend serving reads of a path
+func (fs *SwitchFS) getBackend(path string) (absfs.FileSystem, error) {
+	_, backend, err := fs.lookup(OpOpen, path)
+	return backend, err
+}
+
+// backendFor finds the backend serving an operation on a path, like routeFor
+func (fs *SwitchFS) backendFor(op Operation, path string) (absfs.FileSystem, error) {
+	_, backend, err := fs.routeFor(op, path)
+	return backend, err
+}
+
+// routeFor finds the route and backend serving a call, waiting for the
+// route's operation rate limit
+func (fs *SwitchFS) routeFor(op Operation, path string) (*Route, absfs.FileSystem, error) {
+	return fs.routeContext(fs.ctx, op, path)
+}
+
+// routeContext is like routeFor, but evaluates context conditions against
+// ctx and bounds the rate-limit wait by it
+func (fs *SwitchFS) routeContext(ctx context.Context, op Operation, path string) (*Route, absfs.FileSystem, error) {
+	route, err := fs.findRoute(ctx, op, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	backend, err := fs.backendOf(route, op, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := fs.throttle(ctx, route, op, path); err != nil {
+		return nil, nil, err
+	}
+	return route, backend, nil
+}
+
+// lookup finds the route and backend serving an operation on a path without
+// waiting for rate limits. The route is nil when the default backend serves
+// the path.
+func (fs *SwitchFS) lookup(op Operation, path string) (*Route, absfs.FileSystem, error) {
+	route, err := fs.findRoute(fs.ctx, op, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	backend, err := fs.backendOf(route, op, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return route, backend, nil
+}
+
+// findRoute finds the route serving an operation on a path. With probing
+// on, the first route whose backend holds the path serves it. Otherwise
+// routes with content or context conditions are only chosen when their
+// condition holds for the file and call. The route is nil when the default
+// backend serves the path.
+func (fs *SwitchFS) findRoute(ctx context.Context, op Operation, path string) (*Route, error) {
+	if fs.probes != nil && op.IsWrite() {
+		fs.probes.invalidate(op, path)
+	}
+	if op&(OpRemove|OpRename|OpSymlink) != 0 {
+		fs.links.invalidateTree(path)
+	}
+	if fs.probes != nil && fs.probes.handles(op) {
+		if route, found := fs.probeRoute(ctx, op, path); found {
+			return route, nil
+		}
+	}
+
+	return fs.chooseRoute(ctx, op, path)
+}
+
+// chooseRoute finds the route serving an operation on a path without
+// probing, evaluating the conditions of routes with content or context
+// conditions. The route is nil when the default backend serves the path.
+func (fs *SwitchFS) chooseRoute(ctx context.Context, op Operation, path string) (*Route, error) {
+	route, err := fs.routeOperation(op, path)
+	if err == nil && route.selective() {
+		route, err = fs.selectRoute(ctx, op, path)
+	}
 	if err == ErrNoRoute {
+		return nil, nil
+	}
+	return route, err
+}
+
+// selectRoute finds the first route matching a path whose context and
+// dispatch conditions hold for the call and, for routes with content or file
+// information conditions, whose backend holds a matching file or any file
+// there, respectively
+func (fs *SwitchFS) selectRoute(ctx context.Context, op Operation, path string) (*Route, error) {
+	for _, route := range fs.routesFor(op, path) {
+		if !fs.admits(ctx, route, path) {
+			continue
+		}
+		if route.sniffs && !holdsContent(route, path) {
+			continue
+		}
+		if route.inspects && !holds(route, path) {
+			continue
+		}
+		return route, nil
+	}
+	return nil, ErrNoRoute
+}
+
+// backendOf returns the backend of a route serving an operation on a path.
+// Read operations go to the route's read backend when one is configured,
+// and mutating operations on read-only routes are rejected. A nil route
+// stands for the default backend.
+func (fs *SwitchFS) backendOf(route *Route, op Operation, path string) (absfs.FileSystem, error) {
+	if route == nil {
 		// Use default backend if no route matches
 		if fs.defaultFS != nil {
 			return fs.defaultFS, nil
 		}
 		return nil, ErrNoRoute
 	}
-	return backend, err
+	if route.ReadOnly && op.IsWrite() {
+		return nil, &os.PathError{Op: op.String(), Path: path, Err: os.ErrPermission}
+	}
+	return route.backendFor(op, path), nil
+}
+
+// throttle waits for a route's operation rate limit
+func (fs *SwitchFS) throttle(ctx context.Context, route *Route, op Operation, path string) error {
+	if route == nil || route.limits == nil {
+		return nil
+	}
+	if err := route.limits.wait(ctx, route.limits.ops, 1); err != nil {
+		return rateError(op.String(), path, err)
+	}
+	return nil
+}
+
+// quotaFor returns the quota tracker of a route, or nil
+func quotaFor(route *Route) *quotaTracker {
+	if route == nil {
+		return nil
+	}
+	return route.quota
+}
+
+// idsFor returns the ID mapper of a route, or nil
+func idsFor(route *Route) *idMapper {
+	if route == nil {
+		return nil
+	}
+	return route.ids
+}
+
+// limitsFor returns the rate limiters of a route, or nil
+func limitsFor(route *Route) *routeLimits {
+	if route == nil {
+		return nil
+	}
+	return route.limits
 }
 
 // getBackendAndRewrite finds the backend and rewrites the path if needed
@@ -99,13 +375,60 @@ func (fs *SwitchFS) TempDir() string {
 	return fs.tempDir
 }
 
-// OpenFile opens a file with the specified flags and permissions
+// OpenFile opens a file with the specified flags and permissions. New files
+// on paths routed by content are only created on a backend once 512 bytes
+// are written or another method, such as Close, is called; until then no
+// backend holds them. O_EXCL is checked against every backend such a file
+// may be placed on when OpenFile is called.
 func (fs *SwitchFS) OpenFile(name string, flag int, perm os.FileMode) (absfs.File, error) {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(openOperation(flag), name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// New files on routes with content conditions are placed once their
+	// first bytes are known
+	if flag&os.O_CREATE != 0 {
+		if f, ok, err := fs.createByContent(name, flag, perm); ok {
+			return f, err
+		}
+	}
+
+	route, backend, err := fs.routeFor(openOperation(flag), name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.openOn(route, backend, name, flag, perm)
+}
+
+// openOn opens a file on a route's backend, applying the route's mode
+// policy, quota and bandwidth limits
+func (fs *SwitchFS) openOn(route *Route, backend absfs.FileSystem, name string, flag int, perm os.FileMode) (absfs.File, error) {
+	// Apply the route's mode policy to files this call may create
+	created := false
+	if flag&os.O_CREATE != 0 && route.hasModePolicy() {
+		perm = route.createMode(perm, false)
+		_, statErr := backend.Stat(name)
+		created = statErr != nil
+	}
+
+	var f absfs.File
+	var err error
+	if quota := quotaFor(route); quota != nil && openOperation(flag).IsWrite() {
+		f, err = quota.openFile(backend, name, flag, perm)
+	} else {
+		f, err = backend.OpenFile(name, flag, perm)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return backend.OpenFile(name, flag, perm)
+	if created {
+		if err := enforceMode(backend, perm, name); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return limitFile(fs.ctx, limitsFor(route), f), nil
 }
 
 // Open opens a file for reading
@@ -120,177 +443,204 @@ func (fs *SwitchFS) Create(name string) (absfs.File, error) {
 
 // Mkdir creates a directory
 func (fs *SwitchFS) Mkdir(name string, perm os.FileMode) error {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpMkdir, name, false)
 	if err != nil {
 		return err
 	}
-	return backend.Mkdir(name, perm)
+	return fs.mkdir(name, perm, false)
 }
 
 // MkdirAll creates a directory and all parent directories
 func (fs *SwitchFS) MkdirAll(name string, perm os.FileMode) error {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpMkdir, name, true)
 	if err != nil {
 		return err
 	}
-	return backend.MkdirAll(name, perm)
+	return fs.mkdir(name, perm, true)
 }
 
-// Remove removes a file or empty directory
-func (fs *SwitchFS) Remove(name string) error {
-	backend, err := fs.getBackend(name)
+// mkdir creates a directory, with its parents when all is set, on the
+// backend serving it or, on spread paths, on every backend files there may be
+// placed on. Failures on backends the directory itself would not be routed
+// to, such as an unhealthy backend of a status route, are not reported.
+func (fs *SwitchFS) mkdir(name string, perm os.FileMode, all bool) error {
+	places, err := fs.placements(OpMkdir, name)
 	if err != nil {
 		return err
 	}
-	return backend.Remove(name)
-}
-
-// RemoveAll removes a path and all children
-func (fs *SwitchFS) RemoveAll(path string) error {
-	backend, err := fs.getBackend(path)
-	if err != nil {
-		return err
+	if places == nil {
+		route, backend, err := fs.routeFor(OpMkdir, name)
+		if err != nil {
+			return err
+		}
+		places = []placement{{route: route, backend: backend, admitted: true}}
 	}
-	return backend.RemoveAll(path)
-}
 
-// Rename renames (moves) oldpath to newpath
-func (fs *SwitchFS) Rename(oldpath, newpath string) error {
-	oldBackend, err := fs.getBackend(oldpath)
-	if err != nil {
-		return err
+	var firstErr error
+	for _, p := range places {
+		if err := mkdirOn(p.route, p.backend, name, perm, all); err != nil && p.admitted && firstErr == nil {
+			firstErr = err
+		}
 	}
+	return firstErr
+}
 
-	newBackend, err := fs.getBackend(newpath)
-	if err != nil {
-		return err
+// mkdirOn creates a directory on a route's backend, applying the route's
+// mode policy
+func mkdirOn(route *Route, backend absfs.FileSystem, name string, perm os.FileMode, all bool) error {
+	if !all {
+		if !route.hasModePolicy() {
+			return backend.Mkdir(name, perm)
+		}
+		perm = route.createMode(perm, true)
+		if err := backend.Mkdir(name, perm); err != nil {
+			return err
+		}
+		return enforceMode(backend, perm, name)
 	}
 
-	// If both paths are on the same backend, use native rename
-	if oldBackend == newBackend {
-		return oldBackend.Rename(oldpath, newpath)
+	if !route.hasModePolicy() {
+		return backend.MkdirAll(name, perm)
 	}
-
-	// Cross-backend rename: copy then delete
-	return fs.crossBackendMove(oldpath, newpath, oldBackend, newBackend)
-}
-
-// crossBackendMove handles moving files and directories across different backends
-func (fs *SwitchFS) crossBackendMove(oldpath, newpath string, oldBackend, newBackend absfs.FileSystem) error {
-	// Get file info
-	info, err := oldBackend.Stat(oldpath)
-	if err != nil {
+	perm = route.createMode(perm, true)
+	missing := missingDirs(backend, name)
+	if err := backend.MkdirAll(name, perm); err != nil {
 		return err
 	}
-
-	// Handle directories recursively
-	if info.IsDir() {
-		return fs.crossBackendMoveDir(oldpath, newpath, oldBackend, newBackend, info)
-	}
-
-	// Handle regular files
-	return fs.crossBackendMoveFile(oldpath, newpath, oldBackend, newBackend)
+	return enforceMode(backend, perm, missing...)
 }
 
-// crossBackendMoveFile handles moving a single file across backends
-func (fs *SwitchFS) crossBackendMoveFile(oldpath, newpath string, oldBackend, newBackend absfs.FileSystem) error {
-	// Open source file
-	src, err := oldBackend.Open(oldpath)
+// Remove removes a file or empty directory
+func (fs *SwitchFS) Remove(name string) error {
+	name, err := fs.resolve(OpRemove, name, false)
 	if err != nil {
 		return err
 	}
-	defer src.Close()
 
-	// Create destination file
-	dst, err := newBackend.Create(newpath)
+	// Directories on spread paths are removed everywhere
+	places, err := fs.placements(OpRemove, name)
 	if err != nil {
 		return err
 	}
-	defer dst.Close()
-
-	// Copy data
-	if _, err := io.Copy(dst, src); err != nil {
-		return err
+	if dirs := dirPlacements(places, name); len(dirs) > 0 {
+		return removeEach(dirs, name, func(backend absfs.FileSystem) func(string) error {
+			return backend.Remove
+		})
 	}
 
-	// Close destination to flush
-	if err := dst.Close(); err != nil {
+	route, backend, err := fs.routeFor(OpRemove, name)
+	if err != nil {
 		return err
 	}
-
-	// Remove source
-	return oldBackend.Remove(oldpath)
+	if quota := quotaFor(route); quota != nil {
+		return quota.remove(backend, name, backend.Remove)
+	}
+	return backend.Remove(name)
 }
 
-// crossBackendMoveDir handles moving a directory recursively across backends
-func (fs *SwitchFS) crossBackendMoveDir(oldpath, newpath string, oldBackend, newBackend absfs.FileSystem, info os.FileInfo) error {
-	// Create destination directory with same permissions
-	if err := newBackend.MkdirAll(newpath, info.Mode()); err != nil {
+// RemoveAll removes a path and all children
+func (fs *SwitchFS) RemoveAll(path string) error {
+	path, err := fs.resolve(OpRemove, path, false)
+	if err != nil {
 		return err
 	}
 
-	// Open source directory
-	dir, err := oldBackend.Open(oldpath)
+	// Trees on spread paths may be split over several backends
+	places, err := fs.placements(OpRemove, path)
 	if err != nil {
 		return err
 	}
-	defer dir.Close()
+	if places != nil {
+		return removeEach(places, path, func(backend absfs.FileSystem) func(string) error {
+			return backend.RemoveAll
+		})
+	}
 
-	// Read all directory entries
-	entries, err := dir.Readdir(-1)
+	route, backend, err := fs.routeFor(OpRemove, path)
 	if err != nil {
 		return err
 	}
+	if quota := quotaFor(route); quota != nil {
+		return quota.remove(backend, path, backend.RemoveAll)
+	}
+	return backend.RemoveAll(path)
+}
 
-	// Copy each entry recursively
-	for _, entry := range entries {
-		// Skip "." and ".." entries to avoid infinite recursion
-		name := entry.Name()
-		if name == "." || name == ".." {
-			continue
-		}
-
-		srcPath := path.Join(oldpath, name)
-		dstPath := path.Join(newpath, name)
-
-		if entry.IsDir() {
-			// Recursively copy subdirectory
-			if err := fs.crossBackendMoveDir(srcPath, dstPath, oldBackend, newBackend, entry); err != nil {
-				return err
-			}
+// removeEach runs a removal on every placement, releasing quota usage, and
+// returns the first error
+func removeEach(places []placement, name string, removal func(absfs.FileSystem) func(string) error) error {
+	var firstErr error
+	for _, p := range places {
+		removeFn := removal(p.backend)
+		var err error
+		if quota := quotaFor(p.route); quota != nil {
+			err = quota.remove(p.backend, name, removeFn)
 		} else {
-			// Copy file
-			if err := fs.crossBackendMoveFile(srcPath, dstPath, oldBackend, newBackend); err != nil {
-				return err
-			}
+			err = removeFn(name)
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
 		}
 	}
+	return firstErr
+}
 
-	// Remove source directory after successful copy
-	return oldBackend.RemoveAll(oldpath)
+// Rename renames (moves) oldpath to newpath
+func (fs *SwitchFS) Rename(oldpath, newpath string) error {
+	return fs.RenameContext(fs.ctx, oldpath, newpath, nil)
 }
 
 // Stat returns file information
 func (fs *SwitchFS) Stat(name string) (os.FileInfo, error) {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpStat, name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	route, backend, err := fs.routeFor(OpStat, name)
 	if err != nil {
 		return nil, err
 	}
-	return backend.Stat(name)
+	info, err := backend.Stat(name)
+	if os.IsNotExist(err) {
+		route, backend = fs.spreadDir(OpStat, name, route, backend)
+		info, err = backend.Stat(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return idsFor(route).fileInfo(info), nil
 }
 
 // Chmod changes file permissions
 func (fs *SwitchFS) Chmod(name string, mode os.FileMode) error {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpChmod, name, true)
 	if err != nil {
 		return err
 	}
+
+	route, backend, err := fs.routeFor(OpChmod, name)
+	if err != nil {
+		return err
+	}
+	if route != nil && route.ClampChmod {
+		info, err := backend.Stat(name)
+		if err != nil {
+			return err
+		}
+		mode = info.Mode().Type() | route.chmodMode(mode, info.IsDir())
+	}
 	return backend.Chmod(name, mode)
 }
 
 // Chtimes changes file access and modification times
 func (fs *SwitchFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpChtimes, name, true)
+	if err != nil {
+		return err
+	}
+
+	backend, err := fs.backendFor(OpChtimes, name)
 	if err != nil {
 		return err
 	}
@@ -299,19 +649,33 @@ func (fs *SwitchFS) Chtimes(name string, atime time.Time, mtime time.Time) error
 
 // Chown changes file owner and group
 func (fs *SwitchFS) Chown(name string, uid, gid int) error {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpChown, name, true)
 	if err != nil {
 		return err
 	}
+
+	route, backend, err := fs.routeFor(OpChown, name)
+	if err != nil {
+		return err
+	}
+	uid, gid = idsFor(route).toBackend(uid, gid)
 	return backend.Chown(name, uid, gid)
 }
 
 // Truncate changes the size of a file
 func (fs *SwitchFS) Truncate(name string, size int64) error {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpTruncate, name, true)
 	if err != nil {
 		return err
 	}
+
+	route, backend, err := fs.routeFor(OpTruncate, name)
+	if err != nil {
+		return err
+	}
+	if quota := quotaFor(route); quota != nil {
+		return quota.truncate(backend, name, size)
+	}
 	return backend.Truncate(name, size)
 }
 
@@ -322,25 +686,64 @@ func (fs *SwitchFS) Router() Router {
 
 // ReadDir reads the named directory and returns a list of directory entries
 func (fs *SwitchFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpReadDir, name, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listings on spread paths are merged from every backend
+	places, err := fs.placements(OpReadDir, name)
+	if err != nil {
+		return nil, err
+	}
+	if places != nil {
+		return readDirPlacements(places, name)
+	}
+
+	route, backend, err := fs.routeFor(OpReadDir, name)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := backend.ReadDir(name)
 	if err != nil {
 		return nil, err
 	}
-	return backend.ReadDir(name)
+	return idsFor(route).dirEntries(entries), nil
 }
 
 // ReadFile reads the named file and returns its contents
 func (fs *SwitchFS) ReadFile(name string) ([]byte, error) {
-	backend, err := fs.getBackend(name)
+	name, err := fs.resolve(OpReadFile, name, true)
 	if err != nil {
 		return nil, err
 	}
-	return backend.ReadFile(name)
+
+	route, backend, err := fs.routeFor(OpReadFile, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := backend.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pay for the bytes read against the route's read bandwidth
+	if limits := limitsFor(route); limits != nil {
+		if err := limits.transfer(fs.ctx, limits.read, len(data)); err != nil {
+			return nil, rateError("readfile", name, err)
+		}
+	}
+	return data, nil
 }
 
 // Sub returns a Filer corresponding to the subtree rooted at dir
 func (fs *SwitchFS) Sub(dir string) (fs.FS, error) {
-	backend, err := fs.getBackend(dir)
+	dir, err := fs.resolve(OpStat, dir, true)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := fs.backendFor(OpOpen, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,305 @@
+package switchfs
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/absfs/absfs"
+)
+
+// Ensure SwitchFS implements absfs.SymlinkFileSystem
+var _ absfs.SymlinkFileSystem = (*SwitchFS)(nil)
+
+// maxSymlinkHops bounds the number of symlinks followed while resolving a
+// single path, so that link cycles are reported instead of looping forever
+const maxSymlinkHops = 40
+
+// Symlink creates newname as a symbolic link to oldname on the backend that
+// newname routes to. Link targets are interpreted in the SwitchFS namespace,
+// so a link may point into a different route.
+func (fs *SwitchFS) Symlink(oldname, newname string) error {
+	resolved, err := fs.resolve(OpSymlink, newname, false)
+	if err != nil {
+		return err
+	}
+
+	backend, err := fs.backendFor(OpSymlink, resolved)
+	if err != nil {
+		return err
+	}
+	linker, ok := backend.(absfs.SymLinker)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrSymlinksNotSupported}
+	}
+	return linker.Symlink(oldname, resolved)
+}
+
+// Readlink returns the target of the named symbolic link
+func (fs *SwitchFS) Readlink(name string) (string, error) {
+	resolved, err := fs.resolve(OpReadlink, name, false)
+	if err != nil {
+		return "", err
+	}
+
+	backend, err := fs.backendFor(OpReadlink, resolved)
+	if err != nil {
+		return "", err
+	}
+	linker, ok := backend.(absfs.SymLinker)
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: ErrSymlinksNotSupported}
+	}
+	return linker.Readlink(resolved)
+}
+
+// Lstat returns file information without following a final symbolic link.
+// Backends without symlink support cannot contain links, so Stat is used.
+func (fs *SwitchFS) Lstat(name string) (os.FileInfo, error) {
+	resolved, err := fs.resolve(OpStat, name, false)
+	if err != nil {
+		return nil, err
+	}
+
+	route, backend, err := fs.routeFor(OpStat, resolved)
+	if err != nil {
+		return nil, err
+	}
+	info, err := lstatBackend(backend, resolved)
+	if os.IsNotExist(err) {
+		route, backend = fs.spreadDir(OpStat, resolved, route, backend)
+		info, err = lstatBackend(backend, resolved)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return idsFor(route).fileInfo(info), nil
+}
+
+// Lchown changes the owner and group of a file without following a final
+// symbolic link. Backends without symlink support fall back to Chown.
+func (fs *SwitchFS) Lchown(name string, uid, gid int) error {
+	resolved, err := fs.resolve(OpChown, name, false)
+	if err != nil {
+		return err
+	}
+
+	route, backend, err := fs.routeFor(OpChown, resolved)
+	if err != nil {
+		return err
+	}
+	uid, gid = idsFor(route).toBackend(uid, gid)
+	if linker, ok := backend.(absfs.SymLinker); ok {
+		return linker.Lchown(resolved, uid, gid)
+	}
+	return backend.Chown(resolved, uid, gid)
+}
+
+// lstatBackend returns file information from a backend without following a
+// final symbolic link, using Stat on backends without symlink support
+func lstatBackend(backend absfs.FileSystem, name string) (os.FileInfo, error) {
+	if linker, ok := backend.(absfs.SymLinker); ok {
+		return linker.Lstat(name)
+	}
+	return backend.Stat(name)
+}
+
+// resolve follows symbolic links in name through the router, one path
+// component at a time, so that a link on one backend can point into another
+// route. Components are looked up on the backends serving op, so routes
+// scoped to other operations do not hide links. The final component is only
+// followed when followLast is set.
+// Resolution stops at the first component that does not exist, leaving the
+// remainder for the backend to report. Relative paths are returned unchanged.
+func (fs *SwitchFS) resolve(op Operation, name string, followLast bool) (string, error) {
+	if !path.IsAbs(name) {
+		return name, nil
+	}
+
+	components := splitPath(name)
+	resolved := "/"
+	hops := 0
+
+	for i := 0; i < len(components); i++ {
+		next := path.Join(resolved, components[i])
+		if i == len(components)-1 && !followLast {
+			return next, nil
+		}
+
+		target, isLink, exists := fs.readlinkIfLink(op, next)
+		if !exists {
+			return path.Join(append([]string{next}, components[i+1:]...)...), nil
+		}
+		if !isLink {
+			resolved = next
+			continue
+		}
+
+		hops++
+		if hops > maxSymlinkHops {
+			return "", &os.PathError{Op: op.String(), Path: name, Err: ErrSymlinkLoop}
+		}
+
+		// Restart from the link target with the remaining components
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(next), target)
+		}
+		components = splitPath(path.Join(append([]string{target}, components[i+1:]...)...))
+		resolved = "/"
+		i = -1
+	}
+
+	return resolved, nil
+}
+
+// readlinkIfLink reports whether name exists and, if it is a symbolic link on
+// a backend that supports them, its target. The path is looked up for the
+// operation being resolved, without probing, which would try every backend
+// for each component. What backends report is cached.
+func (fs *SwitchFS) readlinkIfLink(op Operation, name string) (target string, isLink, exists bool) {
+	route, err := fs.chooseRoute(fs.ctx, op, name)
+	if err != nil {
+		return "", false, false
+	}
+	backend := fs.defaultFS
+	if route != nil {
+		backend = route.backendFor(op, name)
+	}
+	if backend == nil {
+		return "", false, false
+	}
+	linker, ok := backend.(absfs.SymLinker)
+	if !ok {
+		return "", false, true
+	}
+
+	if entry, ok := fs.links.get(backend, name); ok {
+		return entry.target, entry.isLink, true
+	}
+	info, err := linker.Lstat(name)
+	if err != nil {
+		return "", false, false
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if target, err = linker.Readlink(name); err != nil {
+			return "", false, false
+		}
+	}
+	entry := linkEntry{target: target, isLink: info.Mode()&os.ModeSymlink != 0}
+	fs.links.put(backend, name, entry)
+	return entry.target, entry.isLink, true
+}
+
+// maxLinkEntries bounds the number of path components the link cache
+// remembers
+const maxLinkEntries = 4096
+
+// defaultLinkTTL is how long the link cache trusts what a backend reported
+// about a path component
+const defaultLinkTTL = 10 * time.Second
+
+// linkCache remembers which existing path components of a backend are
+// symbolic links, so that resolving a path does not look up every component
+// on every call. Entries expire after the cache's TTL, so that links changed
+// on backends directly are noticed.
+type linkCache struct {
+	ttl   time.Duration
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[linkKey]linkEntry
+}
+
+// linkKey identifies a path on a backend
+type linkKey struct {
+	backend absfs.FileSystem
+	name    string
+}
+
+// linkEntry is what a backend reported about an existing path
+type linkEntry struct {
+	target  string
+	isLink  bool
+	expires time.Time
+}
+
+// newLinkCache creates an empty link cache whose entries expire after ttl.
+// A zero ttl disables caching.
+func newLinkCache(ttl time.Duration) *linkCache {
+	return &linkCache{
+		ttl:     ttl,
+		clock:   SystemClock,
+		entries: make(map[linkKey]linkEntry),
+	}
+}
+
+// cacheable reports whether a backend can key the cache. Backends of
+// incomparable types are looked up on every call.
+func cacheable(backend absfs.FileSystem) bool {
+	return reflect.TypeOf(backend).Comparable()
+}
+
+// get returns the cached entry for a path on a backend
+func (c *linkCache) get(backend absfs.FileSystem, name string) (linkEntry, bool) {
+	if c.ttl <= 0 || !cacheable(backend) {
+		return linkEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := linkKey{backend, name}
+	entry, ok := c.entries[key]
+	if ok && c.clock.Now().After(entry.expires) {
+		delete(c.entries, key)
+		return linkEntry{}, false
+	}
+	return entry, ok
+}
+
+// put caches the entry for a path on a backend
+func (c *linkCache) put(backend absfs.FileSystem, name string, entry linkEntry) {
+	if c.ttl <= 0 || !cacheable(backend) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	if len(c.entries) >= maxLinkEntries {
+		for key, cached := range c.entries {
+			if now.After(cached.expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxLinkEntries {
+			clear(c.entries)
+		}
+	}
+	entry.expires = now.Add(c.ttl)
+	c.entries[linkKey{backend, name}] = entry
+}
+
+// invalidateTree drops the entries at or below a path on every backend
+func (c *linkCache) invalidateTree(root string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	prefix := strings.TrimSuffix(root, "/") + "/"
+	for key := range c.entries {
+		if key.name == root || strings.HasPrefix(key.name, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// splitPath splits a cleaned absolute path into its components
+func splitPath(name string) []string {
+	name = strings.Trim(path.Clean(name), "/")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
@@ -0,0 +1,270 @@
+package switchfs
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/memfs"
+)
+
+// noSymlinkFS hides the symlink methods of a backend
+type noSymlinkFS struct {
+	absfs.FileSystem
+}
+
+func TestSymlink_CrossRoute(t *testing.T) {
+	backendA, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+	backendB, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+
+	fs, err := New(
+		WithRoute("/a", backendA, WithPriority(100)),
+		WithRoute("/b", backendB, WithPriority(100)),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	backendA.MkdirAll("/a", 0755)
+	backendB.MkdirAll("/b/dir", 0755)
+	writeTestFile(t, backendB, "/b/dir/target.txt", "on backend b")
+
+	t.Run("file link into another route", func(t *testing.T) {
+		if err := fs.Symlink("/b/dir/target.txt", "/a/link.txt"); err != nil {
+			t.Fatalf("Symlink() error = %v", err)
+		}
+
+		data, err := fs.ReadFile("/a/link.txt")
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if string(data) != "on backend b" {
+			t.Errorf("ReadFile() = %q, want %q", data, "on backend b")
+		}
+
+		target, err := fs.Readlink("/a/link.txt")
+		if err != nil {
+			t.Fatalf("Readlink() error = %v", err)
+		}
+		if target != "/b/dir/target.txt" {
+			t.Errorf("Readlink() = %q, want %q", target, "/b/dir/target.txt")
+		}
+
+		info, err := fs.Lstat("/a/link.txt")
+		if err != nil {
+			t.Fatalf("Lstat() error = %v", err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("Lstat() mode = %v, want symlink", info.Mode())
+		}
+	})
+
+	t.Run("directory link in the middle of a path", func(t *testing.T) {
+		if err := fs.Symlink("/b/dir", "/a/dirlink"); err != nil {
+			t.Fatalf("Symlink() error = %v", err)
+		}
+
+		f, err := fs.Create("/a/dirlink/new.txt")
+		if err != nil {
+			t.Fatalf("Create() through link error = %v", err)
+		}
+		f.Write([]byte("written through link"))
+		f.Close()
+
+		data, err := backendB.ReadFile("/b/dir/new.txt")
+		if err != nil {
+			t.Fatalf("file not created on backend b: %v", err)
+		}
+		if string(data) != "written through link" {
+			t.Errorf("content = %q", data)
+		}
+	})
+
+	t.Run("relative link target", func(t *testing.T) {
+		if err := fs.Symlink("../b/dir/target.txt", "/a/rel.txt"); err != nil {
+			t.Fatalf("Symlink() error = %v", err)
+		}
+		if _, err := fs.Stat("/a/rel.txt"); err != nil {
+			t.Errorf("Stat() through relative link error = %v", err)
+		}
+	})
+
+	t.Run("loop detection", func(t *testing.T) {
+		if err := fs.Symlink("/b/loop2", "/a/loop1"); err != nil {
+			t.Fatalf("Symlink() error = %v", err)
+		}
+		if err := fs.Symlink("/a/loop1", "/b/loop2"); err != nil {
+			t.Fatalf("Symlink() error = %v", err)
+		}
+
+		_, err := fs.Stat("/a/loop1")
+		if !errors.Is(err, ErrSymlinkLoop) {
+			t.Errorf("Stat() error = %v, want ErrSymlinkLoop", err)
+		}
+
+		// The link itself is still visible
+		if _, err := fs.Lstat("/a/loop1"); err != nil {
+			t.Errorf("Lstat() error = %v", err)
+		}
+	})
+}
+
+func TestSymlink_UnsupportedBackend(t *testing.T) {
+	mem, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+	mem.MkdirAll("/plain", 0755)
+	writeTestFile(t, mem, "/plain/file.txt", "data")
+
+	fs, err := New(WithRoute("/plain", &noSymlinkFS{FileSystem: mem}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	err = fs.Symlink("/plain/file.txt", "/plain/link")
+	if !errors.Is(err, ErrSymlinksNotSupported) {
+		t.Errorf("Symlink() error = %v, want ErrSymlinksNotSupported", err)
+	}
+
+	_, err = fs.Readlink("/plain/file.txt")
+	if !errors.Is(err, ErrSymlinksNotSupported) {
+		t.Errorf("Readlink() error = %v, want ErrSymlinksNotSupported", err)
+	}
+
+	// Lstat falls back to Stat since such backends cannot contain links
+	info, err := fs.Lstat("/plain/file.txt")
+	if err != nil {
+		t.Fatalf("Lstat() error = %v", err)
+	}
+	if info.Size() != 4 {
+		t.Errorf("Lstat() size = %d, want 4", info.Size())
+	}
+}
+
+// lstatCountingFS counts the Lstat calls a backend serves
+type lstatCountingFS struct {
+	*memfs.FileSystem
+	lstats int
+}
+
+func (l *lstatCountingFS) Lstat(name string) (os.FileInfo, error) {
+	l.lstats++
+	return l.FileSystem.Lstat(name)
+}
+
+func TestSymlink_Resolution(t *testing.T) {
+	mem, _ := memfs.NewFS()
+	counted := &lstatCountingFS{FileSystem: mem}
+	landing, _ := memfs.NewFS()
+	other, _ := memfs.NewFS()
+
+	fs, err := New(
+		WithRoute("/a", counted, WithPriority(100)),
+		WithRoute("/ingest", landing, WithPriority(100), WithOperations(OpCreate|OpSymlink)),
+		WithRoute("/b", other, WithPriority(100)),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	mem.MkdirAll("/a/deep/path", 0755)
+	landing.MkdirAll("/ingest", 0755)
+	other.MkdirAll("/b", 0755)
+	writeTestFile(t, mem, "/a/deep/path/file.txt", "data")
+
+	t.Run("components are cached", func(t *testing.T) {
+		fs.Stat("/a/deep/path/file.txt")
+		counted.lstats = 0
+		for i := 0; i < 3; i++ {
+			if _, err := fs.Stat("/a/deep/path/file.txt"); err != nil {
+				t.Fatalf("Stat() error = %v", err)
+			}
+		}
+		if counted.lstats != 0 {
+			t.Errorf("backend Lstat called %d times for cached components", counted.lstats)
+		}
+	})
+
+	t.Run("mutations drop cached components", func(t *testing.T) {
+		if err := fs.RemoveAll("/a/deep"); err != nil {
+			t.Fatalf("RemoveAll() error = %v", err)
+		}
+		if err := fs.Symlink("/b", "/a/deep"); err != nil {
+			t.Fatalf("Symlink() error = %v", err)
+		}
+		writeTestFile(t, fs, "/a/deep/moved.txt", "moved")
+		if _, err := other.Stat("/b/moved.txt"); err != nil {
+			t.Errorf("file not written through the new link: %v", err)
+		}
+	})
+
+	t.Run("cached components expire", func(t *testing.T) {
+		now := time.Now()
+		fs.links.clock = ClockFunc(func() time.Time { return now })
+		fs.Stat("/a/deep/moved.txt")
+		counted.lstats = 0
+
+		fs.Stat("/a/deep/moved.txt")
+		if counted.lstats != 0 {
+			t.Errorf("backend Lstat called %d times within the TTL", counted.lstats)
+		}
+		now = now.Add(defaultLinkTTL + time.Second)
+		fs.Stat("/a/deep/moved.txt")
+		if counted.lstats == 0 {
+			t.Error("backend not asked again once cached components expired")
+		}
+	})
+
+	t.Run("links on operation-scoped routes", func(t *testing.T) {
+		if err := fs.Symlink("/b", "/ingest/drop"); err != nil {
+			t.Fatalf("Symlink() error = %v", err)
+		}
+		writeTestFile(t, fs, "/ingest/drop/upload.txt", "upload")
+		if _, err := other.Stat("/b/upload.txt"); err != nil {
+			t.Errorf("create not followed through the landing route's link: %v", err)
+		}
+	})
+}
+
+func TestSymlink_LinkCacheDisabled(t *testing.T) {
+	mem, _ := memfs.NewFS()
+	counted := &lstatCountingFS{FileSystem: mem}
+	other, _ := memfs.NewFS()
+
+	fs, err := New(
+		WithRoute("/a", counted),
+		WithRoute("/b", other),
+		WithLinkCacheTTL(0),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	mem.MkdirAll("/a/dir", 0755)
+	other.MkdirAll("/b", 0755)
+	writeTestFile(t, mem, "/a/dir/file.txt", "data")
+
+	fs.Stat("/a/dir/file.txt")
+	counted.lstats = 0
+	fs.Stat("/a/dir/file.txt")
+	if counted.lstats == 0 {
+		t.Error("components cached with the link cache disabled")
+	}
+
+	// A link made on the backend directly is followed at once
+	mem.RemoveAll("/a/dir")
+	if err := mem.Symlink("/b", "/a/dir"); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	writeTestFile(t, other, "/b/file.txt", "linked")
+	if data, err := fs.ReadFile("/a/dir/file.txt"); err != nil || string(data) != "linked" {
+		t.Errorf("ReadFile() = %q, %v, want the file through the new link", data, err)
+	}
+}
@@ -0,0 +1,622 @@
+package switchfs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/absfs/absfs"
+)
+
+// transferBufferSize is the chunk size used when streaming file data between backends
+const transferBufferSize = 32 * 1024
+
+// TransferProgress reports the state of a cross-backend transfer
+type TransferProgress struct {
+	// Path is the source path of the file most recently transferred
+	Path string
+
+	// FilesDone is the number of files fully transferred so far
+	FilesDone int
+
+	// FilesTotal is the number of files to transfer
+	FilesTotal int
+
+	// BytesDone is the number of bytes transferred so far
+	BytesDone int64
+
+	// BytesTotal is the number of bytes to transfer
+	BytesTotal int64
+}
+
+// SymlinkPolicy controls how symbolic links are transferred
+type SymlinkPolicy int
+
+const (
+	// SymlinkRecreateOrDereference recreates links as links when the
+	// destination backend supports them and copies the data they point to
+	// otherwise
+	SymlinkRecreateOrDereference SymlinkPolicy = iota
+	// SymlinkRecreate recreates links as links and fails with
+	// ErrSymlinksNotSupported if the destination backend cannot store them
+	SymlinkRecreate
+	// SymlinkDereference copies the data links point to
+	SymlinkDereference
+	// SymlinkSkip leaves links out of the transfer
+	SymlinkSkip
+)
+
+// SpecialFilePolicy controls how devices, named pipes and sockets are transferred
+type SpecialFilePolicy int
+
+const (
+	// SpecialFileError fails the transfer with ErrSpecialFile
+	SpecialFileError SpecialFilePolicy = iota
+	// SpecialFileSkip leaves special files out of the transfer. Skipped
+	// files, and the directories holding them, are not removed by a move.
+	SpecialFileSkip
+)
+
+// specialFileModes are the mode bits of files that have no data to copy
+const specialFileModes = os.ModeDevice | os.ModeCharDevice | os.ModeNamedPipe | os.ModeSocket | os.ModeIrregular
+
+// TransferOptions configures cross-backend transfers
+type TransferOptions struct {
+	// Progress is called whenever the transfer advances. It may be nil.
+	// Calls are serialized even when files are copied concurrently.
+	Progress func(TransferProgress)
+
+	// Concurrency is the maximum number of files copied at once. Values
+	// below 2 copy files sequentially.
+	Concurrency int
+
+	// MaxInFlightBytes bounds the combined size of files being copied at
+	// once. A file larger than the bound is copied on its own. Zero means
+	// no bound.
+	MaxInFlightBytes int64
+
+	// Verify hashes each file while it is streamed and re-reads the
+	// destination to confirm it matches before the source is removed. A
+	// mismatch fails the transfer with an error wrapping ErrChecksumMismatch.
+	Verify bool
+
+	// NewHash creates the hash used by Verify. Defaults to SHA-256.
+	NewHash func() hash.Hash
+
+	// Symlinks controls how symbolic links are transferred
+	Symlinks SymlinkPolicy
+
+	// SpecialFiles controls how devices, named pipes and sockets are transferred
+	SpecialFiles SpecialFilePolicy
+}
+
+// transferEntry is a single file, directory or symbolic link scheduled for transfer
+type transferEntry struct {
+	backend absfs.FileSystem
+	src     string
+	dst     string
+	info    os.FileInfo
+	target  string
+}
+
+// transfer copies a file or directory tree from one backend to another
+type transfer struct {
+	ctx      context.Context
+	fs       *SwitchFS
+	opts     TransferOptions
+	src      absfs.FileSystem
+	dst      absfs.FileSystem
+	dirs     []transferEntry
+	links    []transferEntry
+	files    []transferEntry
+	owned    []string
+	skipped  []string
+	visiting map[string]bool
+	mu       sync.Mutex
+	progress TransferProgress
+}
+
+// newTransfer creates a transfer between two backends
+func newTransfer(ctx context.Context, fs *SwitchFS, src, dst absfs.FileSystem, opts *TransferOptions) *transfer {
+	t := &transfer{ctx: ctx, fs: fs, src: src, dst: dst, visiting: make(map[string]bool)}
+	if opts != nil {
+		t.opts = *opts
+	}
+	return t
+}
+
+// RenameContext renames (moves) oldpath to newpath like Rename, but stops
+// promptly when ctx is cancelled and reports progress of cross-backend
+// transfers through opts. The source is only removed once everything has
+// been copied. opts may be nil.
+func (fs *SwitchFS) RenameContext(ctx context.Context, oldpath, newpath string, opts *TransferOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	oldpath, err := fs.resolve(OpRename, oldpath, false)
+	if err != nil {
+		return err
+	}
+	newpath, err = fs.resolve(OpRename, newpath, false)
+	if err != nil {
+		return err
+	}
+
+	oldRoute, oldBackend, err := fs.routeContext(ctx, OpRename, oldpath)
+	if err != nil {
+		return err
+	}
+
+	newRoute, newBackend, err := fs.routeContext(ctx, OpRename, newpath)
+	if err != nil {
+		return err
+	}
+
+	return transferQuota(quotaFor(oldRoute), quotaFor(newRoute), oldBackend, newBackend, "rename", oldpath, newpath, true, func() error {
+		// If both paths are on the same backend, use native rename
+		if oldBackend == newBackend {
+			return oldBackend.Rename(oldpath, newpath)
+		}
+
+		// Cross-backend rename: copy then delete
+		return fs.crossBackendMove(ctx, oldpath, newpath, oldBackend, newBackend, opts)
+	})
+}
+
+// CopyContext copies oldpath to newpath, recursively for directories, using
+// the same transfer engine as cross-backend renames. The source is left in
+// place. opts may be nil.
+func (fs *SwitchFS) CopyContext(ctx context.Context, oldpath, newpath string, opts *TransferOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	oldpath, err := fs.resolve(OpOpen, oldpath, true)
+	if err != nil {
+		return err
+	}
+	newpath, err = fs.resolve(OpCreate, newpath, false)
+	if err != nil {
+		return err
+	}
+
+	_, oldBackend, err := fs.routeContext(ctx, OpOpen, oldpath)
+	if err != nil {
+		return err
+	}
+
+	newRoute, newBackend, err := fs.routeContext(ctx, OpCreate, newpath)
+	if err != nil {
+		return err
+	}
+
+	info, err := oldBackend.Stat(oldpath)
+	if err != nil {
+		return err
+	}
+	if fs.probes != nil {
+		fs.probes.invalidateTree(newpath)
+	}
+	fs.links.invalidateTree(newpath)
+
+	return transferQuota(nil, quotaFor(newRoute), oldBackend, newBackend, "copy", oldpath, newpath, false, func() error {
+		t := newTransfer(ctx, fs, oldBackend, newBackend, opts)
+		if err := t.plan(oldBackend, oldpath, newpath, info, false); err != nil {
+			return err
+		}
+		return t.run()
+	})
+}
+
+// crossBackendMove handles moving files and directories across different backends
+func (fs *SwitchFS) crossBackendMove(ctx context.Context, oldpath, newpath string, oldBackend, newBackend absfs.FileSystem, opts *TransferOptions) error {
+	// Get file info without following a symbolic link being moved
+	info, err := lstatBackend(oldBackend, oldpath)
+	if err != nil {
+		return err
+	}
+
+	t := newTransfer(ctx, fs, oldBackend, newBackend, opts)
+	if err := t.plan(oldBackend, oldpath, newpath, info, true); err != nil {
+		return err
+	}
+	if err := t.run(); err != nil {
+		return err
+	}
+	return t.removeSource(oldpath, info)
+}
+
+// plan walks the source tree and records every directory, file and symbolic
+// link to transfer. Owned entries belong to the tree being moved and are
+// removed once the transfer succeeds; entries reached by dereferencing a link
+// are not.
+func (t *transfer) plan(backend absfs.FileSystem, src, dst string, info os.FileInfo, owned bool) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+
+	switch mode := info.Mode(); {
+	case mode&os.ModeSymlink != 0:
+		return t.planLink(backend, src, dst, info, owned)
+
+	case mode&specialFileModes != 0:
+		if t.opts.SpecialFiles == SpecialFileSkip {
+			t.skipped = append(t.skipped, src)
+			return nil
+		}
+		return &os.PathError{Op: "transfer", Path: src, Err: ErrSpecialFile}
+
+	case !info.IsDir():
+		t.files = append(t.files, transferEntry{backend: backend, src: src, dst: dst, info: info})
+		t.progress.FilesTotal++
+		t.progress.BytesTotal += info.Size()
+		t.own(src, owned)
+		return nil
+	}
+
+	t.dirs = append(t.dirs, transferEntry{backend: backend, src: src, dst: dst, info: info})
+	t.own(src, owned)
+
+	// Open source directory
+	dir, err := backend.Open(src)
+	if err != nil {
+		return err
+	}
+	entries, err := dir.Readdir(-1)
+	dir.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		// Skip "." and ".." entries to avoid infinite recursion
+		name := entry.Name()
+		if name == "." || name == ".." {
+			continue
+		}
+		if err := t.plan(backend, path.Join(src, name), path.Join(dst, name), entry, owned); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planLink records a symbolic link to be recreated, or plans the transfer of
+// whatever it points to, according to the symlink policy
+func (t *transfer) planLink(backend absfs.FileSystem, src, dst string, info os.FileInfo, owned bool) error {
+	_, dstLinks := t.dst.(absfs.SymLinker)
+	linker, srcLinks := backend.(absfs.SymLinker)
+
+	recreate := false
+	switch t.opts.Symlinks {
+	case SymlinkSkip:
+		t.skipped = append(t.skipped, src)
+		return nil
+	case SymlinkRecreate:
+		if !dstLinks {
+			return &os.PathError{Op: "symlink", Path: dst, Err: ErrSymlinksNotSupported}
+		}
+		recreate = true
+	case SymlinkRecreateOrDereference:
+		recreate = dstLinks
+	}
+
+	if recreate && srcLinks {
+		target, err := linker.Readlink(src)
+		if err != nil {
+			return err
+		}
+		t.links = append(t.links, transferEntry{backend: backend, src: src, dst: dst, info: info, target: target})
+		t.progress.FilesTotal++
+		t.own(src, owned)
+		return nil
+	}
+
+	// Dereference through the router, so links into other routes are followed
+	resolved, err := t.fs.resolve(OpOpen, src, true)
+	if err != nil {
+		return err
+	}
+	if t.visiting[resolved] {
+		return &os.PathError{Op: "transfer", Path: src, Err: ErrSymlinkLoop}
+	}
+	t.visiting[resolved] = true
+	defer delete(t.visiting, resolved)
+
+	_, target, err := t.fs.lookup(OpOpen, resolved)
+	if err != nil {
+		return err
+	}
+	targetInfo, err := target.Stat(resolved)
+	if err != nil {
+		return err
+	}
+
+	t.own(src, owned)
+	return t.plan(target, resolved, dst, targetInfo, false)
+}
+
+// own records a source path to remove once a move succeeds
+func (t *transfer) own(src string, owned bool) {
+	if owned {
+		t.owned = append(t.owned, src)
+	}
+}
+
+// removeSource removes the moved source tree. Skipped entries stay behind,
+// along with the directories holding them.
+func (t *transfer) removeSource(root string, info os.FileInfo) error {
+	if len(t.skipped) == 0 {
+		if info.IsDir() {
+			return t.src.RemoveAll(root)
+		}
+		return t.src.Remove(root)
+	}
+
+	// Remove children before their parents
+	for i := len(t.owned) - 1; i >= 0; i-- {
+		name := t.owned[i]
+		if t.holdsSkipped(name) {
+			continue
+		}
+		if err := t.src.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// holdsSkipped reports whether dir contains a skipped entry
+func (t *transfer) holdsSkipped(dir string) bool {
+	for _, name := range t.skipped {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// run creates all planned directories, parents first, then copies all
+// planned files, concurrently if configured
+func (t *transfer) run() error {
+	for _, d := range t.dirs {
+		if err := t.ctx.Err(); err != nil {
+			return err
+		}
+		// Create destination directory with same permissions
+		if err := t.dst.MkdirAll(d.dst, d.info.Mode()); err != nil {
+			return err
+		}
+	}
+
+	for _, l := range t.links {
+		if err := t.ctx.Err(); err != nil {
+			return err
+		}
+		if err := t.dst.(absfs.SymLinker).Symlink(l.target, l.dst); err != nil {
+			return err
+		}
+		t.advance(l.src, 0, 1)
+	}
+
+	if t.opts.Concurrency < 2 {
+		for _, f := range t.files {
+			if err := t.copyFile(t.ctx, f); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return t.runParallel()
+}
+
+// runParallel copies planned files with a bounded pool of workers. The first
+// failure cancels the remaining copies and is returned.
+func (t *transfer) runParallel() error {
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	limiter := newByteLimiter(t.opts.MaxInFlightBytes)
+	work := make(chan transferEntry)
+
+	for i := 0; i < t.opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range work {
+				if err := t.copyFile(ctx, f); err != nil {
+					fail(err)
+				}
+				limiter.release(f.info.Size())
+			}
+		}()
+	}
+
+	for _, f := range t.files {
+		limiter.acquire(f.info.Size())
+		if ctx.Err() != nil {
+			limiter.release(f.info.Size())
+			break
+		}
+		work <- f
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return t.ctx.Err()
+}
+
+// copyFile streams a single file to the destination backend, removing the
+// partial destination if the copy fails
+func (t *transfer) copyFile(ctx context.Context, f transferEntry) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Open source file
+	src, err := f.backend.Open(f.src)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Create destination file
+	dst, err := t.dst.Create(f.dst)
+	if err != nil {
+		return err
+	}
+
+	// Copy data, hashing it on the way through when verifying
+	var r io.Reader = &contextReader{ctx: ctx, r: src}
+	var h hash.Hash
+	if t.opts.Verify {
+		h = t.newHash()
+		r = io.TeeReader(r, h)
+	}
+	w := &progressWriter{w: dst, t: t, path: f.src}
+	_, err = io.CopyBuffer(w, r, make([]byte, transferBufferSize))
+
+	// Close destination to flush
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && h != nil {
+		err = t.verify(ctx, f.dst, h.Sum(nil))
+	}
+	if err != nil {
+		t.dst.Remove(f.dst)
+		return err
+	}
+
+	t.advance(f.src, 0, 1)
+	return nil
+}
+
+// newHash creates the hash used to verify transferred data
+func (t *transfer) newHash() hash.Hash {
+	if t.opts.NewHash != nil {
+		return t.opts.NewHash()
+	}
+	return sha256.New()
+}
+
+// verify re-reads a destination file and compares its hash with want
+func (t *transfer) verify(ctx context.Context, name string, want []byte) error {
+	f, err := t.dst.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := t.newHash()
+	if _, err := io.CopyBuffer(h, &contextReader{ctx: ctx, r: f}, make([]byte, transferBufferSize)); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), want) {
+		return &os.PathError{Op: "verify", Path: name, Err: ErrChecksumMismatch}
+	}
+	return nil
+}
+
+// advance records transferred bytes and files and delivers the updated
+// progress to the progress callback
+func (t *transfer) advance(path string, bytes int64, files int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.progress.Path = path
+	t.progress.BytesDone += bytes
+	t.progress.FilesDone += files
+	if t.opts.Progress != nil {
+		t.opts.Progress(t.progress)
+	}
+}
+
+// contextReader fails reads once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// progressWriter counts bytes written towards the transfer's progress
+type progressWriter struct {
+	w    io.Writer
+	t    *transfer
+	path string
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.t.advance(w.path, int64(n), 0)
+	}
+	return n, err
+}
+
+// byteLimiter bounds the number of bytes in flight across concurrent copies
+type byteLimiter struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+func newByteLimiter(limit int64) *byteLimiter {
+	l := &byteLimiter{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire blocks until n bytes fit within the limit. A request larger than
+// the limit is admitted once nothing else is in flight.
+func (l *byteLimiter) acquire(n int64) {
+	if l.limit <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.used > 0 && l.used+n > l.limit {
+		l.cond.Wait()
+	}
+	l.used += n
+}
+
+// release returns n bytes to the limiter
+func (l *byteLimiter) release(n int64) {
+	if l.limit <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.used -= n
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
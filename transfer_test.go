@@ -0,0 +1,612 @@
+package switchfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/absfs/absfs"
+	"github.com/absfs/memfs"
+)
+
+// newTransferFS creates a SwitchFS routing /src and /dst to separate memfs backends
+func newTransferFS(t *testing.T) (*SwitchFS, absfs.FileSystem, absfs.FileSystem) {
+	t.Helper()
+
+	src, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+	dst, err := memfs.NewFS()
+	if err != nil {
+		t.Fatalf("NewFS() error = %v", err)
+	}
+
+	fs, err := New(
+		WithRoute("/src", src, WithPriority(100)),
+		WithRoute("/dst", dst, WithPriority(100)),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := src.MkdirAll("/src", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	if err := dst.MkdirAll("/dst", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	return fs, src, dst
+}
+
+// writeTestFile creates a file with the given content on a backend
+func writeTestFile(t *testing.T, backend absfs.FileSystem, name, content string) {
+	t.Helper()
+
+	f, err := backend.Create(name)
+	if err != nil {
+		t.Fatalf("Create(%s) error = %v", name, err)
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatalf("Write(%s) error = %v", name, err)
+	}
+	f.Close()
+}
+
+func TestRenameContext(t *testing.T) {
+	t.Run("reports progress", func(t *testing.T) {
+		fs, src, dst := newTransferFS(t)
+
+		if err := src.MkdirAll("/src/tree/sub", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, src, "/src/tree/a.txt", "hello")
+		writeTestFile(t, src, "/src/tree/sub/b.txt", strings.Repeat("x", 100000))
+
+		var last TransferProgress
+		calls := 0
+		opts := &TransferOptions{
+			Progress: func(p TransferProgress) {
+				calls++
+				last = p
+			},
+		}
+
+		if err := fs.RenameContext(context.Background(), "/src/tree", "/dst/tree", opts); err != nil {
+			t.Fatalf("RenameContext() error = %v", err)
+		}
+
+		if calls == 0 {
+			t.Fatal("Progress was never called")
+		}
+		if last.FilesTotal != 2 || last.FilesDone != 2 {
+			t.Errorf("files = %d/%d, want 2/2", last.FilesDone, last.FilesTotal)
+		}
+		if last.BytesTotal != 100005 || last.BytesDone != 100005 {
+			t.Errorf("bytes = %d/%d, want 100005/100005", last.BytesDone, last.BytesTotal)
+		}
+
+		data, err := dst.ReadFile("/dst/tree/sub/b.txt")
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if len(data) != 100000 {
+			t.Errorf("copied %d bytes, want 100000", len(data))
+		}
+		if _, err := src.Stat("/src/tree"); err == nil {
+			t.Error("source still exists after move")
+		}
+	})
+
+	t.Run("cancelled before start", func(t *testing.T) {
+		fs, src, dst := newTransferFS(t)
+		writeTestFile(t, src, "/src/file.txt", "data")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := fs.RenameContext(ctx, "/src/file.txt", "/dst/file.txt", nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("RenameContext() error = %v, want context.Canceled", err)
+		}
+		if _, err := src.Stat("/src/file.txt"); err != nil {
+			t.Errorf("source removed after cancellation: %v", err)
+		}
+		if _, err := dst.Stat("/dst/file.txt"); err == nil {
+			t.Error("destination created after cancellation")
+		}
+	})
+
+	t.Run("cancelled mid-transfer", func(t *testing.T) {
+		fs, src, dst := newTransferFS(t)
+
+		if err := src.MkdirAll("/src/tree", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, src, "/src/tree/a.txt", strings.Repeat("a", 100000))
+		writeTestFile(t, src, "/src/tree/b.txt", strings.Repeat("b", 100000))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := &TransferOptions{
+			Progress: func(p TransferProgress) {
+				cancel()
+			},
+		}
+
+		err := fs.RenameContext(ctx, "/src/tree", "/dst/tree", opts)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("RenameContext() error = %v, want context.Canceled", err)
+		}
+		for _, name := range []string{"/src/tree/a.txt", "/src/tree/b.txt"} {
+			if _, err := src.Stat(name); err != nil {
+				t.Errorf("source %s removed after cancellation: %v", name, err)
+			}
+		}
+		if _, err := dst.Stat("/dst/tree/a.txt"); err == nil {
+			t.Error("partial destination file left behind")
+		}
+	})
+
+	t.Run("same backend", func(t *testing.T) {
+		fs, src, _ := newTransferFS(t)
+		writeTestFile(t, src, "/src/old.txt", "data")
+
+		if err := fs.RenameContext(context.Background(), "/src/old.txt", "/src/new.txt", nil); err != nil {
+			t.Fatalf("RenameContext() error = %v", err)
+		}
+		if _, err := src.Stat("/src/new.txt"); err != nil {
+			t.Errorf("renamed file missing: %v", err)
+		}
+	})
+}
+
+// trackingFS serializes namespace changes on a backend and records how many
+// files are open for writing at once
+type trackingFS struct {
+	absfs.FileSystem
+	mu       sync.Mutex
+	open     int
+	maxOpen  int
+	failName string
+}
+
+func (t *trackingFS) Create(name string) (absfs.File, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if name == t.failName {
+		return nil, errors.New("create failed")
+	}
+	f, err := t.FileSystem.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	t.open++
+	if t.open > t.maxOpen {
+		t.maxOpen = t.open
+	}
+	return &trackingFile{File: f, fs: t}, nil
+}
+
+func (t *trackingFS) MkdirAll(name string, perm os.FileMode) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.FileSystem.MkdirAll(name, perm)
+}
+
+func (t *trackingFS) Remove(name string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.FileSystem.Remove(name)
+}
+
+type trackingFile struct {
+	absfs.File
+	fs     *trackingFS
+	closed bool
+}
+
+func (f *trackingFile) Write(p []byte) (int, error) {
+	time.Sleep(2 * time.Millisecond)
+	return f.File.Write(p)
+}
+
+func (f *trackingFile) Close() error {
+	f.fs.mu.Lock()
+	if !f.closed {
+		f.closed = true
+		f.fs.open--
+	}
+	f.fs.mu.Unlock()
+	return f.File.Close()
+}
+
+func TestRenameContext_Parallel(t *testing.T) {
+	setup := func(t *testing.T, files int) (*SwitchFS, absfs.FileSystem, *trackingFS) {
+		src, err := memfs.NewFS()
+		if err != nil {
+			t.Fatalf("NewFS() error = %v", err)
+		}
+		mem, err := memfs.NewFS()
+		if err != nil {
+			t.Fatalf("NewFS() error = %v", err)
+		}
+		dst := &trackingFS{FileSystem: mem}
+
+		fs, err := New(
+			WithRoute("/src", src, WithPriority(100)),
+			WithRoute("/dst", dst, WithPriority(100)),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+
+		if err := src.MkdirAll("/src/tree/sub", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := mem.MkdirAll("/dst", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		for i := 0; i < files; i++ {
+			writeTestFile(t, src, fmt.Sprintf("/src/tree/sub/f%02d.txt", i), strings.Repeat("x", 100))
+		}
+		return fs, src, dst
+	}
+
+	t.Run("bounded by file count", func(t *testing.T) {
+		fs, src, dst := setup(t, 20)
+
+		opts := &TransferOptions{Concurrency: 4}
+		if err := fs.RenameContext(context.Background(), "/src/tree", "/dst/tree", opts); err != nil {
+			t.Fatalf("RenameContext() error = %v", err)
+		}
+
+		if dst.maxOpen > 4 {
+			t.Errorf("max concurrent copies = %d, want <= 4", dst.maxOpen)
+		}
+		if dst.maxOpen < 2 {
+			t.Errorf("max concurrent copies = %d, want files copied in parallel", dst.maxOpen)
+		}
+		for i := 0; i < 20; i++ {
+			name := fmt.Sprintf("/dst/tree/sub/f%02d.txt", i)
+			data, err := dst.ReadFile(name)
+			if err != nil || len(data) != 100 {
+				t.Errorf("ReadFile(%s) = %d bytes, %v", name, len(data), err)
+			}
+		}
+		if _, err := src.Stat("/src/tree"); err == nil {
+			t.Error("source still exists after move")
+		}
+	})
+
+	t.Run("bounded by bytes in flight", func(t *testing.T) {
+		fs, _, dst := setup(t, 10)
+
+		opts := &TransferOptions{Concurrency: 8, MaxInFlightBytes: 250}
+		if err := fs.RenameContext(context.Background(), "/src/tree", "/dst/tree", opts); err != nil {
+			t.Fatalf("RenameContext() error = %v", err)
+		}
+		if dst.maxOpen > 2 {
+			t.Errorf("max concurrent copies = %d, want <= 2", dst.maxOpen)
+		}
+	})
+
+	t.Run("failure keeps source", func(t *testing.T) {
+		fs, src, dst := setup(t, 10)
+		dst.failName = "/dst/tree/sub/f05.txt"
+
+		opts := &TransferOptions{Concurrency: 4}
+		if err := fs.RenameContext(context.Background(), "/src/tree", "/dst/tree", opts); err == nil {
+			t.Fatal("RenameContext() should fail when a copy fails")
+		}
+		for i := 0; i < 10; i++ {
+			name := fmt.Sprintf("/src/tree/sub/f%02d.txt", i)
+			if _, err := src.Stat(name); err != nil {
+				t.Errorf("source %s removed after failed move: %v", name, err)
+			}
+		}
+	})
+}
+
+func TestCopyContext(t *testing.T) {
+	fs, src, dst := newTransferFS(t)
+
+	if err := src.MkdirAll("/src/tree/sub", 0755); err != nil {
+		t.Fatalf("MkdirAll() error = %v", err)
+	}
+	writeTestFile(t, src, "/src/tree/a.txt", "alpha")
+	writeTestFile(t, src, "/src/tree/sub/b.txt", "beta")
+
+	opts := &TransferOptions{Concurrency: 2}
+	if err := fs.CopyContext(context.Background(), "/src/tree", "/dst/tree", opts); err != nil {
+		t.Fatalf("CopyContext() error = %v", err)
+	}
+
+	data, err := dst.ReadFile("/dst/tree/sub/b.txt")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if string(data) != "beta" {
+		t.Errorf("content = %q, want %q", data, "beta")
+	}
+	if _, err := src.Stat("/src/tree/a.txt"); err != nil {
+		t.Errorf("source removed by copy: %v", err)
+	}
+}
+
+// corruptingFS flips the first byte of every write to files it creates
+type corruptingFS struct {
+	absfs.FileSystem
+}
+
+func (c *corruptingFS) Create(name string) (absfs.File, error) {
+	f, err := c.FileSystem.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return &corruptingFile{File: f}, nil
+}
+
+type corruptingFile struct {
+	absfs.File
+}
+
+func (f *corruptingFile) Write(p []byte) (int, error) {
+	b := append([]byte(nil), p...)
+	if len(b) > 0 {
+		b[0] ^= 0xff
+	}
+	return f.File.Write(b)
+}
+
+func TestRenameContext_Verify(t *testing.T) {
+	t.Run("matching checksum", func(t *testing.T) {
+		fs, src, dst := newTransferFS(t)
+		if err := src.MkdirAll("/src/tree", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, src, "/src/tree/a.txt", strings.Repeat("a", 70000))
+		writeTestFile(t, src, "/src/tree/b.txt", "b")
+
+		for _, opts := range []*TransferOptions{
+			{Verify: true},
+			{Verify: true, NewHash: func() hash.Hash { return crc32.NewIEEE() }},
+		} {
+			if err := fs.RenameContext(context.Background(), "/src/tree", "/dst/tree", opts); err != nil {
+				t.Fatalf("RenameContext() error = %v", err)
+			}
+			if err := fs.RenameContext(context.Background(), "/dst/tree", "/src/tree", opts); err != nil {
+				t.Fatalf("RenameContext() back error = %v", err)
+			}
+		}
+		if _, err := dst.Stat("/dst/tree"); err == nil {
+			t.Error("destination should have been moved back")
+		}
+		data, err := src.ReadFile("/src/tree/a.txt")
+		if err != nil || len(data) != 70000 {
+			t.Errorf("ReadFile() = %d bytes, %v", len(data), err)
+		}
+	})
+
+	t.Run("mismatch keeps source", func(t *testing.T) {
+		src, err := memfs.NewFS()
+		if err != nil {
+			t.Fatalf("NewFS() error = %v", err)
+		}
+		mem, err := memfs.NewFS()
+		if err != nil {
+			t.Fatalf("NewFS() error = %v", err)
+		}
+		fs, err := New(
+			WithRoute("/src", src, WithPriority(100)),
+			WithRoute("/dst", &corruptingFS{FileSystem: mem}, WithPriority(100)),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		if err := src.MkdirAll("/src", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		if err := mem.MkdirAll("/dst", 0755); err != nil {
+			t.Fatalf("MkdirAll() error = %v", err)
+		}
+		writeTestFile(t, src, "/src/file.txt", "important data")
+
+		err = fs.RenameContext(context.Background(), "/src/file.txt", "/dst/file.txt", &TransferOptions{Verify: true})
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Fatalf("RenameContext() error = %v, want ErrChecksumMismatch", err)
+		}
+		var pathErr *os.PathError
+		if !errors.As(err, &pathErr) || pathErr.Path != "/dst/file.txt" {
+			t.Errorf("error = %v, want PathError naming /dst/file.txt", err)
+		}
+		if _, err := src.Stat("/src/file.txt"); err != nil {
+			t.Errorf("source removed after checksum mismatch: %v", err)
+		}
+		if _, err := mem.Stat("/dst/file.txt"); err == nil {
+			t.Error("corrupt destination left behind")
+		}
+	})
+
+	t.Run("unverified copy does not detect corruption", func(t *testing.T) {
+		src, _ := memfs.NewFS()
+		mem, _ := memfs.NewFS()
+		fs, err := New(
+			WithRoute("/src", src, WithPriority(100)),
+			WithRoute("/dst", &corruptingFS{FileSystem: mem}, WithPriority(100)),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		src.MkdirAll("/src", 0755)
+		mem.MkdirAll("/dst", 0755)
+		writeTestFile(t, src, "/src/file.txt", "data")
+
+		if err := fs.CopyContext(context.Background(), "/src/file.txt", "/dst/file.txt", nil); err != nil {
+			t.Errorf("CopyContext() error = %v", err)
+		}
+	})
+}
+
+func TestRenameContext_Symlinks(t *testing.T) {
+	setup := func(t *testing.T, dstLinks bool) (*SwitchFS, absfs.FileSystem, absfs.FileSystem) {
+		src, err := memfs.NewFS()
+		if err != nil {
+			t.Fatalf("NewFS() error = %v", err)
+		}
+		mem, err := memfs.NewFS()
+		if err != nil {
+			t.Fatalf("NewFS() error = %v", err)
+		}
+		var dst absfs.FileSystem = mem
+		if !dstLinks {
+			dst = &noSymlinkFS{FileSystem: mem}
+		}
+		fs, err := New(
+			WithRoute("/src", src, WithPriority(100)),
+			WithRoute("/dst", dst, WithPriority(100)),
+		)
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		src.MkdirAll("/src/tree", 0755)
+		mem.MkdirAll("/dst", 0755)
+		writeTestFile(t, src, "/src/tree/file.txt", "payload")
+		if err := src.Symlink("/src/tree/file.txt", "/src/tree/link"); err != nil {
+			t.Fatalf("Symlink() error = %v", err)
+		}
+		return fs, src, dst
+	}
+
+	t.Run("recreated when destination supports links", func(t *testing.T) {
+		fs, _, dst := setup(t, true)
+
+		if err := fs.Rename("/src/tree", "/dst/tree"); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+		info, err := dst.(absfs.SymLinker).Lstat("/dst/tree/link")
+		if err != nil {
+			t.Fatalf("Lstat() error = %v", err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Errorf("mode = %v, want symlink", info.Mode())
+		}
+		target, err := dst.(absfs.SymLinker).Readlink("/dst/tree/link")
+		if err != nil || target != "/src/tree/file.txt" {
+			t.Errorf("Readlink() = %q, %v", target, err)
+		}
+	})
+
+	t.Run("top-level link moved as a link", func(t *testing.T) {
+		fs, src, dst := setup(t, true)
+
+		if err := fs.Rename("/src/tree/link", "/dst/link"); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+		if _, err := dst.(absfs.SymLinker).Readlink("/dst/link"); err != nil {
+			t.Errorf("Readlink() error = %v", err)
+		}
+		if _, err := src.Stat("/src/tree/file.txt"); err != nil {
+			t.Errorf("link target removed by move: %v", err)
+		}
+	})
+
+	t.Run("dereferenced when destination lacks links", func(t *testing.T) {
+		fs, _, dst := setup(t, false)
+
+		if err := fs.Rename("/src/tree", "/dst/tree"); err != nil {
+			t.Fatalf("Rename() error = %v", err)
+		}
+		data, err := dst.ReadFile("/dst/tree/link")
+		if err != nil {
+			t.Fatalf("ReadFile() error = %v", err)
+		}
+		if string(data) != "payload" {
+			t.Errorf("content = %q, want %q", data, "payload")
+		}
+	})
+
+	t.Run("recreate policy fails without link support", func(t *testing.T) {
+		fs, src, _ := setup(t, false)
+
+		err := fs.RenameContext(context.Background(), "/src/tree", "/dst/tree", &TransferOptions{Symlinks: SymlinkRecreate})
+		if !errors.Is(err, ErrSymlinksNotSupported) {
+			t.Fatalf("RenameContext() error = %v, want ErrSymlinksNotSupported", err)
+		}
+		if _, err := src.Stat("/src/tree/file.txt"); err != nil {
+			t.Errorf("source removed after failed move: %v", err)
+		}
+	})
+
+	t.Run("skip policy leaves link behind", func(t *testing.T) {
+		fs, src, dst := setup(t, true)
+
+		err := fs.RenameContext(context.Background(), "/src/tree", "/dst/tree", &TransferOptions{Symlinks: SymlinkSkip})
+		if err != nil {
+			t.Fatalf("RenameContext() error = %v", err)
+		}
+		if _, err := dst.Stat("/dst/tree/link"); err == nil {
+			t.Error("skipped link was transferred")
+		}
+		if _, err := src.(absfs.SymLinker).Lstat("/src/tree/link"); err != nil {
+			t.Errorf("skipped link removed from source: %v", err)
+		}
+		if _, err := src.Stat("/src/tree/file.txt"); err == nil {
+			t.Error("transferred file not removed from source")
+		}
+	})
+}
+
+func TestRenameContext_SpecialFiles(t *testing.T) {
+	setup := func(t *testing.T) (*SwitchFS, absfs.FileSystem, absfs.FileSystem) {
+		fs, src, dst := newTransferFS(t)
+		src.MkdirAll("/src/tree/pipes", 0755)
+		writeTestFile(t, src, "/src/tree/file.txt", "data")
+		writeTestFile(t, src, "/src/tree/pipes/fifo", "")
+		if err := src.Chmod("/src/tree/pipes/fifo", os.ModeNamedPipe|0644); err != nil {
+			t.Fatalf("Chmod() error = %v", err)
+		}
+		return fs, src, dst
+	}
+
+	t.Run("error by default", func(t *testing.T) {
+		fs, src, _ := setup(t)
+
+		err := fs.Rename("/src/tree", "/dst/tree")
+		if !errors.Is(err, ErrSpecialFile) {
+			t.Fatalf("Rename() error = %v, want ErrSpecialFile", err)
+		}
+		if _, err := src.Stat("/src/tree/file.txt"); err != nil {
+			t.Errorf("source removed after failed move: %v", err)
+		}
+	})
+
+	t.Run("skip policy", func(t *testing.T) {
+		fs, src, dst := setup(t)
+
+		err := fs.RenameContext(context.Background(), "/src/tree", "/dst/tree", &TransferOptions{SpecialFiles: SpecialFileSkip})
+		if err != nil {
+			t.Fatalf("RenameContext() error = %v", err)
+		}
+		if _, err := dst.Stat("/dst/tree/file.txt"); err != nil {
+			t.Errorf("regular file not transferred: %v", err)
+		}
+		if _, err := dst.Stat("/dst/tree/pipes/fifo"); err == nil {
+			t.Error("special file was transferred")
+		}
+		if _, err := src.Stat("/src/tree/pipes/fifo"); err != nil {
+			t.Errorf("skipped special file removed from source: %v", err)
+		}
+		if _, err := src.Stat("/src/tree/file.txt"); err == nil {
+			t.Error("transferred file not removed from source")
+		}
+	})
+}
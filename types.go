@@ -1,6 +1,11 @@
 package switchfs
 
-import "github.com/absfs/absfs"
+import (
+	"os"
+	"time"
+
+	"github.com/absfs/absfs"
+)
 
 // PatternType defines how patterns are matched
 type PatternType int
@@ -45,14 +50,184 @@ type Route struct {
 	// Failover is an optional backup backend
 	Failover absfs.FileSystem
 
+	// ReadBackend optionally serves read operations, leaving Backend to
+	// serve mutating operations
+	ReadBackend absfs.FileSystem
+
 	// Condition is an optional condition that must be met for routing
 	Condition RouteCondition
 
 	// Rewriter optionally transforms paths before passing to backend
 	Rewriter PathRewriter
 
+	// ReadOnly rejects mutating operations on the route's paths with
+	// os.ErrPermission
+	ReadOnly bool
+
+	// MaxBytes caps the total size of regular files under the route. Zero
+	// means no limit.
+	MaxBytes int64
+
+	// MaxFiles caps the number of regular files under the route. Zero means
+	// no limit.
+	MaxFiles int64
+
+	// OpsPerSecond limits the calls dispatched to the route. Zero means no
+	// limit.
+	OpsPerSecond float64
+
+	// ReadBytesPerSecond limits the bytes read through the route's files.
+	// Zero means no limit.
+	ReadBytesPerSecond int64
+
+	// WriteBytesPerSecond limits the bytes written through the route's
+	// files. Zero means no limit.
+	WriteBytesPerSecond int64
+
+	// RateLimitNoWait fails calls over a rate limit with ErrRateLimited
+	// instead of waiting
+	RateLimitNoWait bool
+
+	// Umask clears permission bits of files and directories created under
+	// the route
+	Umask os.FileMode
+
+	// FileMode and DirMode replace the permissions callers pass when
+	// creating files and directories under the route. Zero keeps the
+	// caller's permissions.
+	FileMode os.FileMode
+	DirMode  os.FileMode
+
+	// ClampChmod limits Chmod on the route to the bits FileMode or DirMode
+	// and Umask allow
+	ClampChmod bool
+
+	// UIDMap and GIDMap translate user and group IDs of the SwitchFS
+	// namespace to the backend's. Chown maps IDs toward the backend; Stat,
+	// Lstat and ReadDir map them back when FileInfo.Sys() carries Uid and
+	// Gid fields. Unmapped IDs pass through.
+	UIDMap map[int]int
+	GIDMap map[int]int
+
+	// Operations is the set of operations the route participates in. Zero
+	// means all operations.
+	Operations Operation
+
+	// Mirrors are additional backends that receive every mutation applied
+	// to Backend. Reads are served by the first healthy replica.
+	Mirrors []absfs.FileSystem
+
+	// WriteQuorum is the number of replicas or balanced targets a mutation
+	// must succeed on. Zero means all of them.
+	WriteQuorum int
+
+	// OnDivergence is called for each replica that failed a mirrored
+	// mutation which still met the write quorum
+	OnDivergence func(Divergence)
+
+	// Shards spreads the route's paths across several backends by
+	// consistent hashing. When set, Backend may be nil.
+	Shards []Shard
+
+	// ShardKey is a regular expression whose first capture group selects
+	// the part of the path to hash, such as a user ID. Paths it does not
+	// match, and all paths when it is empty, hash the first path segment
+	// below the route's prefix. Routes with glob or regex patterns need one.
+	ShardKey string
+
+	// Targets spreads the route's calls across several equivalent backends,
+	// such as replicas of a read-only dataset. When set, Backend may be nil
+	// and Failover serves calls while no target is healthy.
+	Targets []Target
+
+	// Balance is the policy picking a target for each call
+	Balance BalancePolicy
+
+	// HealthCooldown is how long a failed target stays out of rotation.
+	// Zero means 30 seconds.
+	HealthCooldown time.Duration
+
 	// compiled stores the compiled pattern matcher
 	compiled patternMatcher
+
+	// mirror fans operations out to Backend and Mirrors
+	mirror *mirrorFS
+
+	// sharded picks a backend from Shards
+	sharded *shardSet
+
+	// balanced picks a backend from Targets
+	balanced *balancedFS
+
+	// quota tracks usage against MaxBytes and MaxFiles
+	quota *quotaTracker
+
+	// limits enforces OpsPerSecond and the bandwidth limits
+	limits *routeLimits
+
+	// ids translates IDs using UIDMap and GIDMap
+	ids *idMapper
+
+	// sniffs is set when Condition inspects file content
+	sniffs bool
+
+	// contextual is set when Condition inspects the call context
+	contextual bool
+
+	// dispatched is set when Condition is evaluated on every call, and
+	// inspects when it then reads the information of existing files
+	dispatched bool
+	inspects   bool
+
+	// probed is set when SwitchFS probes backends to choose between the
+	// routes matching a path
+	probed bool
+
+	// built is set once buildRoute has built the state above
+	built bool
+}
+
+// operations returns the set of operations the route participates in
+func (r *Route) operations() Operation {
+	if r.Operations == 0 {
+		return OpAll
+	}
+	return r.Operations
+}
+
+// handles reports whether the route participates in an operation
+func (r *Route) handles(op Operation) bool {
+	return r.operations()&op != 0
+}
+
+// selective reports whether dispatch evaluates the route's condition to
+// choose between the routes matching a path
+func (r *Route) selective() bool {
+	return r.sniffs || r.contextual || r.dispatched
+}
+
+// distinguishable reports whether dispatch can choose between the route and
+// others on the same pattern, by evaluating its condition on every call or
+// by probing backends
+func (r *Route) distinguishable() bool {
+	return r.probed || usesContent(r.Condition) || usesContext(r.Condition) || usesDispatch(r.Condition)
+}
+
+// backendFor returns the backend serving an operation on a path of this route
+func (r *Route) backendFor(op Operation, path string) absfs.FileSystem {
+	if r.sharded != nil {
+		return r.sharded.backendFor(path)
+	}
+	if r.balanced != nil {
+		return r.balanced
+	}
+	if r.ReadBackend != nil && !op.IsWrite() {
+		return r.ReadBackend
+	}
+	if r.mirror != nil {
+		return r.mirror
+	}
+	return r.Backend
 }
 
 // Option configures SwitchFS behavior